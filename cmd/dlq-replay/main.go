@@ -0,0 +1,58 @@
+// dlq-replay moves dead-lettered upload events back to the subject they came
+// from, e.g. after the bug that made them fail has been fixed.
+//
+//	go run ./cmd/dlq-replay -url nats://localhost:4222 -limit 10
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	inbound_messaging "video-processor-worker/internal/adapters/inbound/messaging"
+
+	"github.com/nats-io/nats.go"
+)
+
+func main() {
+	url := flag.String("url", getEnv("NATS_URL", "nats://nats1:4222"), "NATS server URL")
+	subject := flag.String("subject", getEnv("NATS_DLQ_SUBJECT", "upload.dlq"), "dead-letter subject to drain")
+	limit := flag.Int("limit", 0, "maximum number of messages to replay, 0 for all")
+	dryRun := flag.Bool("dry-run", false, "only list the messages that would be replayed")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	nc, err := nats.Connect(*url)
+	if err != nil {
+		log.Fatalf("❌ Error connecting to NATS: %v", err)
+	}
+	defer nc.Close()
+
+	js, err := nc.JetStream()
+	if err != nil {
+		log.Fatalf("❌ Error getting JetStream context: %v", err)
+	}
+
+	replayed, err := inbound_messaging.ReplayDeadLetters(ctx, js, *subject, *limit, *dryRun)
+	if err != nil {
+		log.Fatalf("❌ Replay stopped after %d message(s): %v", replayed, err)
+	}
+
+	if *dryRun {
+		log.Printf("✅ %d message(s) would be replayed", replayed)
+		return
+	}
+	log.Printf("✅ Replayed %d message(s) from %s", replayed, *subject)
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,64 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"video-processor-worker/internal/core/domain"
+)
+
+// uploadEvent is the payload every broker adapter receives
+type uploadEvent struct {
+	VideoID  int64                     `json:"video_id"`
+	Filename string                    `json:"filename"`
+	Options  *domain.ExtractionOptions `json:"options,omitempty"`
+}
+
+// decodeEvent parses a payload. Its errors are permanent: redelivering a
+// malformed payload cannot succeed.
+func decodeEvent(data []byte) (uploadEvent, error) {
+	var event uploadEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return event, fmt.Errorf("error unmarshaling event: %w", err)
+	}
+	if event.VideoID <= 0 {
+		return event, errors.New("event without video_id")
+	}
+	return event, nil
+}
+
+// outcome is what an adapter does with a message once its job is over
+type outcome int
+
+const (
+	// outcomeAck acknowledges a processed message
+	outcomeAck outcome = iota
+	// outcomeRetry redelivers the message once the video's backoff is over
+	outcomeRetry
+	// outcomeDeadLetter moves a message that ran out of deliveries aside
+	outcomeDeadLetter
+	// outcomeDrop stops redelivery, the video is already FAILED
+	outcomeDrop
+)
+
+// settle maps the result of a job to an outcome, with the redelivery delay
+// for outcomeRetry. lastDelivery is whether the broker will give up on the
+// message after this delivery.
+func settle(videoID int64, err error, lastDelivery bool) (outcome, time.Duration) {
+	var retry *domain.RetryError
+	switch {
+	case err == nil:
+		return outcomeAck, 0
+	case errors.As(err, &retry) && lastDelivery:
+		log.Printf("❌ Event for video %d ran out of deliveries: %v", videoID, err)
+		return outcomeDeadLetter, 0
+	case errors.As(err, &retry):
+		log.Printf("🔁 Event for video %d redelivered in %s: %v", videoID, retry.Delay, err)
+		return outcomeRetry, retry.Delay
+	default:
+		log.Printf("❌ Error handling event: %v", err)
+		return outcomeDrop, 0
+	}
+}
@@ -0,0 +1,326 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ErrIncompatibleConfig means the stream or consumer found on the server
+// cannot serve the configured consumer without being recreated
+var ErrIncompatibleConfig = errors.New("incompatible NATS configuration")
+
+// NatsConsumerConfig describes the stream and durable consumer the worker reads from
+type NatsConsumerConfig struct {
+	// Stream is the JetStream stream holding the events. When empty it is
+	// looked up by Subject, or named "UPLOADS" when it has to be created.
+	Stream string
+
+	// StreamSubjects are the subjects of the stream when it is provisioned,
+	// Subject and DeadLetterSubject are always included
+	StreamSubjects []string
+
+	// Subject is the subject the consumer filters on
+	Subject string
+
+	// Durable names the pull consumer shared by every replica
+	Durable string
+
+	// DeliverPolicy is where a new consumer starts: all, new, last or last_per_subject
+	DeliverPolicy string
+
+	// AckWait is how long JetStream waits for an acknowledgement before it
+	// redelivers. Running jobs extend it with InProgress every AckWait/3.
+	AckWait time.Duration
+
+	// MaxAckPending bounds the unacknowledged messages over all replicas,
+	// 0 keeps the server default
+	MaxAckPending int
+
+	// MaxDeliver bounds the deliveries of an event, 0 or less means unlimited
+	MaxDeliver int
+
+	// DeadLetterSubject receives the payload of events that cannot be
+	// processed. Without Provision, dead-lettering is turned off when no
+	// stream captures it.
+	DeadLetterSubject string
+
+	// Provision creates the stream and consumer when they are missing and
+	// updates the settings that can change in place. Without it they are
+	// only checked, and a missing consumer is created.
+	Provision bool
+}
+
+func DefaultNatsConsumerConfig() NatsConsumerConfig {
+	return NatsConsumerConfig{
+		Subject:           "upload",
+		Durable:           "worker",
+		DeliverPolicy:     "all",
+		AckWait:           2 * time.Minute,
+		MaxDeliver:        10,
+		DeadLetterSubject: "upload.dlq",
+	}
+}
+
+func (c NatsConsumerConfig) withDefaults() NatsConsumerConfig {
+	defaults := DefaultNatsConsumerConfig()
+	if c.Subject == "" {
+		c.Subject = defaults.Subject
+	}
+	if c.Durable == "" {
+		c.Durable = defaults.Durable
+	}
+	if c.DeliverPolicy == "" {
+		c.DeliverPolicy = defaults.DeliverPolicy
+	}
+	if c.AckWait <= 0 {
+		c.AckWait = defaults.AckWait
+	}
+	return c
+}
+
+// subjects returns every subject a provisioned stream captures
+func (c NatsConsumerConfig) subjects() []string {
+	subjects := slices.Clone(c.StreamSubjects)
+	for _, s := range []string{c.Subject, c.DeadLetterSubject} {
+		if s != "" && !covered(subjects, s) {
+			subjects = append(subjects, s)
+		}
+	}
+	return subjects
+}
+
+func (c NatsConsumerConfig) deliverPolicy() (nats.DeliverPolicy, error) {
+	switch c.DeliverPolicy {
+	case "all":
+		return nats.DeliverAllPolicy, nil
+	case "new":
+		return nats.DeliverNewPolicy, nil
+	case "last":
+		return nats.DeliverLastPolicy, nil
+	case "last_per_subject":
+		return nats.DeliverLastPerSubjectPolicy, nil
+	}
+	return 0, fmt.Errorf("unknown deliver policy %q", c.DeliverPolicy)
+}
+
+func (c NatsConsumerConfig) consumerConfig() (*nats.ConsumerConfig, error) {
+	policy, err := c.deliverPolicy()
+	if err != nil {
+		return nil, err
+	}
+	return &nats.ConsumerConfig{
+		Durable:       c.Durable,
+		FilterSubject: c.Subject,
+		DeliverPolicy: policy,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       c.AckWait,
+		MaxDeliver:    c.maxDeliver(),
+		MaxAckPending: c.MaxAckPending,
+	}, nil
+}
+
+// maxDeliver maps "unlimited" to the value JetStream reports for it
+func (c NatsConsumerConfig) maxDeliver() int {
+	if c.MaxDeliver <= 0 {
+		return -1
+	}
+	return c.MaxDeliver
+}
+
+// provision makes sure the stream and the durable consumer exist and match
+// cfg, filling in cfg.Stream when it was left empty
+func provision(js nats.JetStreamContext, cfg *NatsConsumerConfig) error {
+	want, err := cfg.consumerConfig()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIncompatibleConfig, err)
+	}
+
+	if err := provisionStream(js, cfg); err != nil {
+		return err
+	}
+
+	info, err := js.ConsumerInfo(cfg.Stream, cfg.Durable)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		if _, err := js.AddConsumer(cfg.Stream, want); err != nil {
+			return fmt.Errorf("error creating consumer %s: %w", cfg.Durable, err)
+		}
+		log.Printf("🛠️ Created NATS consumer %s on stream %s", cfg.Durable, cfg.Stream)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching consumer %s: %w", cfg.Durable, err)
+	}
+
+	have := info.Config
+	if have.DeliverSubject != "" {
+		return migratePushConsumer(js, cfg, info, want)
+	}
+	if err := incompatibility(&have, want); err != nil {
+		return fmt.Errorf("%w: consumer %s %v", ErrIncompatibleConfig, cfg.Durable, err)
+	}
+
+	if settingsMatch(&have, want) {
+		return nil
+	}
+	if !cfg.Provision {
+		log.Printf("⚠️ NATS consumer %s differs from the configuration (ack wait %s, max deliver %d, max ack pending %d), using it as is",
+			cfg.Durable, have.AckWait, have.MaxDeliver, have.MaxAckPending)
+		cfg.AckWait = have.AckWait
+		cfg.MaxDeliver = have.MaxDeliver
+		return nil
+	}
+
+	updated := have
+	updated.AckWait = want.AckWait
+	updated.MaxDeliver = want.MaxDeliver
+	if want.MaxAckPending != 0 {
+		updated.MaxAckPending = want.MaxAckPending
+	}
+	if _, err := js.UpdateConsumer(cfg.Stream, &updated); err != nil {
+		return fmt.Errorf("error updating consumer %s: %w", cfg.Durable, err)
+	}
+	log.Printf("🛠️ Updated NATS consumer %s on stream %s", cfg.Durable, cfg.Stream)
+	return nil
+}
+
+// incompatibility returns why a consumer created as have cannot serve want,
+// nil when it can
+func incompatibility(have, want *nats.ConsumerConfig) error {
+	switch {
+	case have.FilterSubject != want.FilterSubject:
+		return fmt.Errorf("filters %q instead of %q", have.FilterSubject, want.FilterSubject)
+	case have.AckPolicy != nats.AckExplicitPolicy:
+		return errors.New("does not use explicit acks")
+	case have.DeliverPolicy != want.DeliverPolicy && have.DeliverPolicy != nats.DeliverByStartSequencePolicy:
+		// Start sequence consumers are migrated push consumers, see migratePushConsumer
+		return errors.New("was created with another deliver policy")
+	}
+	return nil
+}
+
+// settingsMatch reports whether the settings that can change in place already match want
+func settingsMatch(have, want *nats.ConsumerConfig) bool {
+	return have.AckWait == want.AckWait && have.MaxDeliver == want.MaxDeliver &&
+		(want.MaxAckPending == 0 || have.MaxAckPending == want.MaxAckPending)
+}
+
+// migratePushConsumer replaces the push consumer older workers used with a
+// pull consumer of the same name. It starts after the last acknowledged
+// message, so only events that were still unacknowledged are delivered again.
+func migratePushConsumer(js nats.JetStreamContext, cfg *NatsConsumerConfig, info *nats.ConsumerInfo, want *nats.ConsumerConfig) error {
+	migrated := *want
+	migrated.DeliverPolicy = nats.DeliverByStartSequencePolicy
+	migrated.OptStartSeq = info.AckFloor.Stream + 1
+
+	if err := js.DeleteConsumer(cfg.Stream, cfg.Durable); err != nil && !errors.Is(err, nats.ErrConsumerNotFound) {
+		return fmt.Errorf("error deleting push consumer %s: %w", cfg.Durable, err)
+	}
+	if _, err := js.AddConsumer(cfg.Stream, &migrated); err != nil {
+		return fmt.Errorf("error recreating consumer %s: %w", cfg.Durable, err)
+	}
+	log.Printf("🛠️ Recreated push consumer %s as a pull consumer starting at sequence %d", cfg.Durable, migrated.OptStartSeq)
+	return nil
+}
+
+func provisionStream(js nats.JetStreamContext, cfg *NatsConsumerConfig) error {
+	if cfg.Stream == "" {
+		name, err := js.StreamNameBySubject(cfg.Subject)
+		switch {
+		case err == nil:
+			cfg.Stream = name
+		case errors.Is(err, nats.ErrNoMatchingStream) && cfg.Provision:
+			cfg.Stream = "UPLOADS"
+		case errors.Is(err, nats.ErrNoMatchingStream):
+			return fmt.Errorf("%w: no stream captures subject %s", ErrIncompatibleConfig, cfg.Subject)
+		default:
+			return fmt.Errorf("error looking up stream for %s: %w", cfg.Subject, err)
+		}
+	}
+
+	info, err := js.StreamInfo(cfg.Stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		if !cfg.Provision {
+			return fmt.Errorf("%w: stream %s not found", ErrIncompatibleConfig, cfg.Stream)
+		}
+		if _, err := js.AddStream(&nats.StreamConfig{Name: cfg.Stream, Subjects: cfg.subjects()}); err != nil {
+			return fmt.Errorf("error creating stream %s: %w", cfg.Stream, err)
+		}
+		log.Printf("🛠️ Created NATS stream %s (%s)", cfg.Stream, strings.Join(cfg.subjects(), ", "))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching stream %s: %w", cfg.Stream, err)
+	}
+
+	if !cfg.Provision {
+		if !covered(info.Config.Subjects, cfg.Subject) {
+			return fmt.Errorf("%w: stream %s does not capture %s", ErrIncompatibleConfig, cfg.Stream, cfg.Subject)
+		}
+		checkDeadLetterSubject(js, cfg)
+		return nil
+	}
+
+	var missing []string
+	for _, s := range cfg.subjects() {
+		if !covered(info.Config.Subjects, s) {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	updated := info.Config
+	updated.Subjects = append(updated.Subjects, missing...)
+	if _, err := js.UpdateStream(&updated); err != nil {
+		return fmt.Errorf("error adding %s to stream %s: %w", strings.Join(missing, ", "), cfg.Stream, err)
+	}
+	log.Printf("🛠️ Added %s to NATS stream %s", strings.Join(missing, ", "), cfg.Stream)
+	return nil
+}
+
+// checkDeadLetterSubject turns dead-lettering off when no stream captures
+// DeadLetterSubject, since publishing a dead letter would always fail
+func checkDeadLetterSubject(js nats.JetStreamContext, cfg *NatsConsumerConfig) {
+	if cfg.DeadLetterSubject == "" {
+		return
+	}
+	_, err := js.StreamNameBySubject(cfg.DeadLetterSubject)
+	switch {
+	case errors.Is(err, nats.ErrNoMatchingStream):
+		log.Printf("⚠️ No NATS stream captures %s, dead-lettering is disabled. Add it to a stream or set NATS_PROVISION=true.", cfg.DeadLetterSubject)
+		cfg.DeadLetterSubject = ""
+	case err != nil:
+		log.Printf("⚠️ Error looking up stream for %s: %v", cfg.DeadLetterSubject, err)
+	}
+}
+
+// covered reports whether one of the stream subjects, wildcards included, matches subject
+func covered(streamSubjects []string, subject string) bool {
+	for _, pattern := range streamSubjects {
+		if subjectMatches(pattern, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+func subjectMatches(pattern, subject string) bool {
+	p := strings.Split(pattern, ".")
+	s := strings.Split(subject, ".")
+	for i, token := range p {
+		if token == ">" {
+			return len(s) > i
+		}
+		if i >= len(s) || (token != "*" && token != s[i]) {
+			return false
+		}
+	}
+	return len(p) == len(s)
+}
@@ -0,0 +1,121 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubjectMatches(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		matches          bool
+	}{
+		{"upload", "upload", true},
+		{"upload", "upload.dlq", false},
+		{"upload.dlq", "upload", false},
+		{"upload.*", "upload.dlq", true},
+		{"upload.*", "upload", false},
+		{"upload.*", "upload.dlq.retry", false},
+		{"*.dlq", "upload.dlq", true},
+		{"upload.>", "upload.dlq", true},
+		{"upload.>", "upload.dlq.retry", true},
+		{"upload.>", "upload", false},
+		{">", "upload", true},
+		{"*", "upload.dlq", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.matches, subjectMatches(tt.pattern, tt.subject), "%s ~ %s", tt.pattern, tt.subject)
+	}
+
+	assert.True(t, covered([]string{"events.>", "upload.*"}, "upload.dlq"))
+	assert.False(t, covered([]string{"events.>", "upload.*"}, "upload"))
+	assert.False(t, covered(nil, "upload"))
+}
+
+func TestNatsConsumerConfig_Subjects(t *testing.T) {
+	cfg := NatsConsumerConfig{StreamSubjects: []string{"upload.>"}, Subject: "upload.video", DeadLetterSubject: "dlq"}
+	assert.Equal(t, []string{"upload.>", "dlq"}, cfg.subjects())
+
+	cfg = NatsConsumerConfig{Subject: "upload"}
+	assert.Equal(t, []string{"upload"}, cfg.subjects())
+}
+
+func TestNatsConsumerConfig_WithDefaults(t *testing.T) {
+	defaults := DefaultNatsConsumerConfig()
+
+	cfg := NatsConsumerConfig{AckWait: -time.Second}.withDefaults()
+	assert.Equal(t, defaults.Subject, cfg.Subject)
+	assert.Equal(t, defaults.Durable, cfg.Durable)
+	assert.Equal(t, defaults.DeliverPolicy, cfg.DeliverPolicy)
+	assert.Equal(t, defaults.AckWait, cfg.AckWait)
+	assert.Empty(t, cfg.DeadLetterSubject, "dead-lettering stays off when it was left empty")
+	assert.Zero(t, cfg.MaxDeliver)
+
+	custom := NatsConsumerConfig{Subject: "videos", Durable: "w", DeliverPolicy: "new", AckWait: time.Minute}
+	assert.Equal(t, custom, custom.withDefaults())
+}
+
+func TestNatsConsumerConfig_ConsumerConfig(t *testing.T) {
+	policies := map[string]nats.DeliverPolicy{
+		"all":              nats.DeliverAllPolicy,
+		"new":              nats.DeliverNewPolicy,
+		"last":             nats.DeliverLastPolicy,
+		"last_per_subject": nats.DeliverLastPerSubjectPolicy,
+	}
+	for name, policy := range policies {
+		want, err := NatsConsumerConfig{DeliverPolicy: name}.consumerConfig()
+		require.NoError(t, err)
+		assert.Equal(t, policy, want.DeliverPolicy, name)
+	}
+
+	_, err := NatsConsumerConfig{DeliverPolicy: "first"}.consumerConfig()
+	assert.Error(t, err)
+
+	want, err := NatsConsumerConfig{DeliverPolicy: "all", MaxDeliver: 0}.consumerConfig()
+	require.NoError(t, err)
+	assert.Equal(t, -1, want.MaxDeliver, "unlimited is reported as -1 by the server")
+	assert.Equal(t, nats.AckExplicitPolicy, want.AckPolicy)
+}
+
+func TestConsumerCompatibility(t *testing.T) {
+	want, err := NatsConsumerConfig{Subject: "upload", Durable: "worker", DeliverPolicy: "all", AckWait: time.Minute, MaxDeliver: 5}.consumerConfig()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		change     func(c *nats.ConsumerConfig)
+		compatible bool
+		matches    bool
+	}{
+		{"identical", func(c *nats.ConsumerConfig) {}, true, true},
+		{"other filter", func(c *nats.ConsumerConfig) { c.FilterSubject = "videos" }, false, true},
+		{"implicit acks", func(c *nats.ConsumerConfig) { c.AckPolicy = nats.AckAllPolicy }, false, true},
+		{"other deliver policy", func(c *nats.ConsumerConfig) { c.DeliverPolicy = nats.DeliverNewPolicy }, false, true},
+		{"migrated push consumer", func(c *nats.ConsumerConfig) {
+			c.DeliverPolicy = nats.DeliverByStartSequencePolicy
+			c.OptStartSeq = 42
+		}, true, true},
+		{"other max deliver", func(c *nats.ConsumerConfig) { c.MaxDeliver = -1 }, true, false},
+		{"other ack wait", func(c *nats.ConsumerConfig) { c.AckWait = time.Hour }, true, false},
+		{"server default max ack pending", func(c *nats.ConsumerConfig) { c.MaxAckPending = 1000 }, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have := *want
+			tt.change(&have)
+
+			assert.Equal(t, tt.compatible, incompatibility(&have, want) == nil)
+			assert.Equal(t, tt.matches, settingsMatch(&have, want))
+		})
+	}
+
+	limited := *want
+	limited.MaxAckPending = 10
+	assert.False(t, settingsMatch(want, &limited), "a configured max ack pending has to match")
+}
@@ -2,26 +2,31 @@ package messaging
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 	"video-processor-worker/internal/core/ports"
 
 	"github.com/nats-io/nats.go"
 )
 
-type NatsConsumerAdapter struct {
-	nc      *nats.Conn
-	js      nats.JetStreamContext
-	handler func(ctx context.Context, videoID int64) error
-}
+const (
+	// fetchWait bounds a single pull request, slotPollInterval is how often
+	// a full pool is checked for a free slot
+	fetchWait        = 5 * time.Second
+	slotPollInterval = 250 * time.Millisecond
+)
 
-type uploadEvent struct {
-	VideoID  int64  `json:"video_id"`
-	Filename string `json:"filename"`
+type NatsConsumerAdapter struct {
+	nc       *nats.Conn
+	js       nats.JetStreamContext
+	cfg      NatsConsumerConfig
+	executor ports.JobExecutor
+	handler  ports.EventHandler
 }
 
-func NewNatsConsumerAdapter(url string, handler func(ctx context.Context, videoID int64) error) (ports.EventConsumer, error) {
+func NewNatsConsumerAdapter(url string, cfg NatsConsumerConfig, executor ports.JobExecutor, handler ports.EventHandler) (ports.EventConsumer, error) {
 	nc, err := nats.Connect(url)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to NATS: %w", err)
@@ -29,42 +34,152 @@ func NewNatsConsumerAdapter(url string, handler func(ctx context.Context, videoI
 
 	js, err := nc.JetStream()
 	if err != nil {
+		nc.Close()
 		return nil, fmt.Errorf("error getting JetStream context: %w", err)
 	}
 
+	cfg = cfg.withDefaults()
+	if err := provision(js, &cfg); err != nil {
+		nc.Close()
+		return nil, err
+	}
+
 	return &NatsConsumerAdapter{
-		nc:      nc,
-		js:      js,
-		handler: handler,
+		nc:       nc,
+		js:       js,
+		cfg:      cfg,
+		executor: executor,
+		handler:  handler,
 	}, nil
 }
 
 func (a *NatsConsumerAdapter) Listen(ctx context.Context) error {
-	log.Println("👂 Listening for NATS JetStream events on subject 'upload'...")
-
-	// Durable push-based consumer
-	sub, err := a.js.Subscribe("upload", func(m *nats.Msg) {
-		var event uploadEvent
-		if err := json.Unmarshal(m.Data, &event); err != nil {
-			log.Printf("❌ Error unmarshaling event: %v", err)
-			return
+	log.Printf("👂 Listening for NATS JetStream events on subject '%s'...", a.cfg.Subject)
+
+	// Durable pull consumer: each replica asks for as many messages as it
+	// has free slots, so the work spreads over the replicas that have room.
+	// The consumer was created or checked by provision.
+	sub, err := a.js.PullSubscribe(a.cfg.Subject, a.cfg.Durable, nats.Bind(a.cfg.Stream, a.cfg.Durable))
+	if err != nil {
+		return fmt.Errorf("error subscribing to NATS: %w", err)
+	}
+
+	log.Printf("✅ Subscribed to %s", sub.Subject)
+
+	// Unsubscribing would delete the durable consumer, so the subscription
+	// stays open and fetching just stops after ctx is done
+	for ctx.Err() == nil {
+		free := a.executor.FreeSlots()
+		if free == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(slotPollInterval):
+			}
+			continue
 		}
 
-		log.Printf("📥 Received event: video_id=%d, filename=%s", event.VideoID, event.Filename)
+		fetchCtx, cancel := context.WithTimeout(ctx, fetchWait)
+		msgs, err := sub.Fetch(free, nats.Context(fetchCtx))
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+				continue
+			}
+			log.Printf("⚠️ Error fetching NATS events: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
 
-		if err := a.handler(ctx, event.VideoID); err != nil {
-			log.Printf("❌ Error handling event: %v", err)
-			m.Nak()
-			return
+		for _, m := range msgs {
+			a.handle(ctx, m)
 		}
+	}
 
-		m.Ack()
-	}, nats.Durable("worker"), nats.ManualAck())
+	log.Println("👋 Stopped accepting NATS events")
+	return nil
+}
+
+func (a *NatsConsumerAdapter) handle(ctx context.Context, m *nats.Msg) {
+	// Shutting down: hand the message back so another replica picks it up
+	if ctx.Err() != nil {
+		m.Nak()
+		return
+	}
 
+	event, err := decodeEvent(m.Data)
 	if err != nil {
-		return fmt.Errorf("error subscribing to NATS: %w", err)
+		log.Printf("❌ Invalid event: %v", err)
+		a.deadLetter(m, "invalid_payload", err)
+		return
 	}
 
-	log.Printf("✅ Subscribed to %s", sub.Subject)
+	log.Printf("📥 Received event: video_id=%d, filename=%s", event.VideoID, event.Filename)
+
+	err = a.executor.Submit(ctx, func(ctx context.Context) error {
+		stop := a.keepAlive(m)
+		defer stop()
+		return a.handler(ctx, event.VideoID, event.Options)
+	}, func(err error) {
+		switch result, delay := settle(event.VideoID, err, a.lastDelivery(m)); result {
+		case outcomeAck:
+			m.Ack()
+		case outcomeRetry:
+			m.NakWithDelay(delay)
+		case outcomeDeadLetter:
+			a.deadLetter(m, "max_deliver", err)
+		case outcomeDrop:
+			m.Term()
+		}
+	})
+	if err != nil {
+		log.Printf("⚠️ Event for video %d not scheduled: %v", event.VideoID, err)
+		m.Nak()
+	}
+}
+
+// keepAlive resets the AckWait of m periodically so JetStream does not
+// redeliver a job that is still running, until the returned func is called
+func (a *NatsConsumerAdapter) keepAlive(m *nats.Msg) func() {
+	interval := a.cfg.AckWait / 3
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := m.InProgress(); err != nil {
+					log.Printf("⚠️ Error extending ack deadline: %v", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// lastDelivery reports whether the server will not redeliver m after a Nak
+func (a *NatsConsumerAdapter) lastDelivery(m *nats.Msg) bool {
+	if a.cfg.MaxDeliver <= 0 {
+		return false
+	}
+	meta, err := m.Metadata()
+	return err == nil && meta.NumDelivered >= uint64(a.cfg.MaxDeliver)
+}
+
+func (a *NatsConsumerAdapter) Close() error {
+	a.nc.Close()
 	return nil
 }
@@ -0,0 +1,130 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Headers added to dead-lettered messages, next to the original payload
+const (
+	HeaderDeadLetterSubject   = "Dlq-Original-Subject"
+	HeaderDeadLetterStream    = "Dlq-Original-Stream"
+	HeaderDeadLetterSequence  = "Dlq-Original-Sequence"
+	HeaderDeadLetterReason    = "Dlq-Reason"
+	HeaderDeadLetterError     = "Dlq-Error"
+	HeaderDeadLetterDelivered = "Dlq-Deliveries"
+	HeaderDeadLetterFailedAt  = "Dlq-Failed-At"
+)
+
+var eventsDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "worker_events_dead_lettered_total",
+	Help: "Total number of events moved to the dead-letter subject",
+}, []string{"reason"})
+
+// deadLetter copies m to the dead-letter subject and terminates it. If the
+// copy cannot be stored m is left for redelivery instead.
+func (a *NatsConsumerAdapter) deadLetter(m *nats.Msg, reason string, cause error) {
+	if a.cfg.DeadLetterSubject == "" {
+		m.Term()
+		return
+	}
+
+	dlq := nats.NewMsg(a.cfg.DeadLetterSubject)
+	dlq.Data = m.Data
+	dlq.Header.Set(HeaderDeadLetterSubject, m.Subject)
+	dlq.Header.Set(HeaderDeadLetterReason, reason)
+	dlq.Header.Set(HeaderDeadLetterError, cause.Error())
+	dlq.Header.Set(HeaderDeadLetterFailedAt, time.Now().UTC().Format(time.RFC3339))
+	if meta, err := m.Metadata(); err == nil {
+		dlq.Header.Set(HeaderDeadLetterStream, meta.Stream)
+		dlq.Header.Set(HeaderDeadLetterSequence, strconv.FormatUint(meta.Sequence.Stream, 10))
+		dlq.Header.Set(HeaderDeadLetterDelivered, strconv.FormatUint(meta.NumDelivered, 10))
+	}
+
+	if _, err := a.js.PublishMsg(dlq); err != nil {
+		log.Printf("❌ Error publishing to dead-letter subject %s: %v", a.cfg.DeadLetterSubject, err)
+		m.Nak()
+		return
+	}
+
+	log.Printf("☠️ Event moved to %s (%s): %v", a.cfg.DeadLetterSubject, reason, cause)
+	eventsDeadLettered.WithLabelValues(reason).Inc()
+	m.Term()
+}
+
+// ReplayDeadLetters publishes up to limit dead-lettered messages back to the
+// subject they came from, without the dead-letter headers, and deletes them
+// from their stream. A limit of 0 or less replays everything. With dryRun the
+// messages are only listed and left in place.
+func ReplayDeadLetters(ctx context.Context, js nats.JetStreamContext, subject string, limit int, dryRun bool) (int, error) {
+	// Ephemeral consumer, it goes away once the replay is done
+	sub, err := js.PullSubscribe(subject, "", nats.DeliverAll(), nats.AckExplicit(), nats.InactiveThreshold(time.Minute))
+	if err != nil {
+		return 0, fmt.Errorf("error subscribing to %s: %w", subject, err)
+	}
+	defer sub.Unsubscribe()
+
+	replayed := 0
+	for limit <= 0 || replayed < limit {
+		batch := 50
+		if limit > 0 {
+			batch = min(batch, limit-replayed)
+		}
+
+		// Fetch takes either a MaxWait or a context, never both
+		fetchCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		msgs, err := sub.Fetch(batch, nats.Context(fetchCtx))
+		cancel()
+		if ctx.Err() == nil && (errors.Is(err, nats.ErrTimeout) || errors.Is(err, context.DeadlineExceeded)) {
+			// Nothing left in the dead-letter subject
+			break
+		}
+		if err != nil {
+			return replayed, fmt.Errorf("error fetching from %s: %w", subject, err)
+		}
+
+		for _, m := range msgs {
+			original := m.Header.Get(HeaderDeadLetterSubject)
+			if original == "" {
+				original = "upload"
+			}
+
+			log.Printf("🔁 %s -> %s (%s: %s)", subject, original, m.Header.Get(HeaderDeadLetterReason), m.Header.Get(HeaderDeadLetterError))
+			if dryRun {
+				// Listed messages stay unacknowledged, a redelivery means we went full circle
+				if meta, err := m.Metadata(); err == nil && meta.NumDelivered > 1 {
+					return replayed, nil
+				}
+				replayed++
+				continue
+			}
+
+			replay := nats.NewMsg(original)
+			replay.Data = m.Data
+			if _, err := js.PublishMsg(replay, nats.Context(ctx)); err != nil {
+				m.Nak()
+				return replayed, fmt.Errorf("error publishing to %s: %w", original, err)
+			}
+			// The stream keeps acknowledged messages, so the copy is deleted
+			// for good or the next run would replay it again
+			meta, err := m.Metadata()
+			if err != nil {
+				return replayed, fmt.Errorf("error reading replayed message metadata: %w", err)
+			}
+			if err := js.DeleteMsg(meta.Stream, meta.Sequence.Stream, nats.Context(ctx)); err != nil {
+				return replayed, fmt.Errorf("error deleting replayed message: %w", err)
+			}
+			m.Ack()
+			replayed++
+		}
+	}
+	return replayed, nil
+}
@@ -0,0 +1,342 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"video-processor-worker/internal/core/ports"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisPayloadField is the stream entry field holding the JSON event
+const redisPayloadField = "data"
+
+// RedisStreamConfig describes the stream and consumer group the Redis consumer works with
+type RedisStreamConfig struct {
+	URL    string
+	Stream string
+	Group  string
+
+	// Consumer names this replica in the group, the hostname when empty
+	Consumer string
+
+	// MinIdle is how long an entry stays pending before another consumer may
+	// claim it. Entries being processed are kept alive.
+	MinIdle time.Duration
+
+	// ClaimInterval is how often pending entries over MinIdle are claimed
+	ClaimInterval time.Duration
+
+	// MaxDeliver bounds the deliveries of an event, 0 or less means unlimited
+	MaxDeliver int
+
+	// DeadLetterStream receives the payload of events that cannot be processed
+	DeadLetterStream string
+}
+
+func DefaultRedisStreamConfig() RedisStreamConfig {
+	return RedisStreamConfig{
+		URL:              "redis://redis:6379/0",
+		Stream:           "upload",
+		Group:            "worker",
+		MinIdle:          5 * time.Minute,
+		ClaimInterval:    30 * time.Second,
+		MaxDeliver:       10,
+		DeadLetterStream: "upload.dlq",
+	}
+}
+
+type RedisStreamConsumerAdapter struct {
+	client   *redis.Client
+	cfg      RedisStreamConfig
+	executor ports.JobExecutor
+	handler  ports.EventHandler
+}
+
+func NewRedisStreamConsumerAdapter(cfg RedisStreamConfig, executor ports.JobExecutor, handler ports.EventHandler) (ports.EventConsumer, error) {
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+
+	if cfg.Consumer == "" {
+		cfg.Consumer, _ = os.Hostname()
+	}
+	if cfg.MinIdle <= 0 {
+		cfg.MinIdle = DefaultRedisStreamConfig().MinIdle
+	}
+	if cfg.ClaimInterval <= 0 {
+		cfg.ClaimInterval = DefaultRedisStreamConfig().ClaimInterval
+	}
+
+	a := &RedisStreamConsumerAdapter{
+		client:   redis.NewClient(opts),
+		cfg:      cfg,
+		executor: executor,
+		handler:  handler,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := a.ensureGroup(ctx); err != nil {
+		a.client.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// ensureGroup creates the stream and the consumer group if they do not exist.
+// A new group starts at the beginning of the stream so events published
+// before the first worker started are not lost.
+func (a *RedisStreamConsumerAdapter) ensureGroup(ctx context.Context) error {
+	err := a.client.XGroupCreateMkStream(ctx, a.cfg.Stream, a.cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("error creating consumer group %s on %s: %w", a.cfg.Group, a.cfg.Stream, err)
+	}
+	return nil
+}
+
+func (a *RedisStreamConsumerAdapter) Listen(ctx context.Context) error {
+	log.Printf("👂 Listening for Redis stream events on '%s' as %s/%s...", a.cfg.Stream, a.cfg.Group, a.cfg.Consumer)
+
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		free := a.executor.FreeSlots()
+		if free == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(slotPollInterval):
+			}
+			continue
+		}
+
+		var msgs []redis.XMessage
+		var err error
+		if time.Since(lastClaim) >= a.cfg.ClaimInterval {
+			lastClaim = time.Now()
+			msgs, err = a.claimIdle(ctx, free)
+		}
+		if err == nil && len(msgs) == 0 {
+			msgs, err = a.read(ctx, free)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			log.Printf("⚠️ Error reading Redis stream events: %v", err)
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				if err := a.ensureGroup(ctx); err != nil {
+					log.Printf("⚠️ %v", err)
+				}
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		for _, m := range msgs {
+			a.handle(ctx, m)
+		}
+	}
+
+	log.Println("👋 Stopped accepting Redis stream events")
+	return nil
+}
+
+// claimIdle takes over up to count entries left pending longer than MinIdle,
+// by a replica that died or by retry
+func (a *RedisStreamConsumerAdapter) claimIdle(ctx context.Context, count int) ([]redis.XMessage, error) {
+	msgs, _, err := a.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   a.cfg.Stream,
+		Group:    a.cfg.Group,
+		Consumer: a.cfg.Consumer,
+		MinIdle:  a.cfg.MinIdle,
+		Start:    "0-0",
+		Count:    int64(count),
+	}).Result()
+	return msgs, err
+}
+
+// read waits up to fetchWait for up to count new entries
+func (a *RedisStreamConsumerAdapter) read(ctx context.Context, count int) ([]redis.XMessage, error) {
+	streams, err := a.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    a.cfg.Group,
+		Consumer: a.cfg.Consumer,
+		Streams:  []string{a.cfg.Stream, ">"},
+		Count:    int64(count),
+		Block:    fetchWait,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []redis.XMessage
+	for _, s := range streams {
+		msgs = append(msgs, s.Messages...)
+	}
+	return msgs, nil
+}
+
+func (a *RedisStreamConsumerAdapter) handle(ctx context.Context, m redis.XMessage) {
+	// Shutting down: make the entry claimable by another replica right away
+	if ctx.Err() != nil {
+		a.retry(m.ID, 0)
+		return
+	}
+
+	payload, _ := m.Values[redisPayloadField].(string)
+	event, err := decodeEvent([]byte(payload))
+	if err != nil {
+		log.Printf("❌ Invalid event: %v", err)
+		a.deadLetter(m, "invalid_payload", err)
+		return
+	}
+
+	log.Printf("📥 Received event: video_id=%d, filename=%s", event.VideoID, event.Filename)
+
+	err = a.executor.Submit(ctx, func(ctx context.Context) error {
+		stop := a.keepAlive(m.ID)
+		defer stop()
+		return a.handler(ctx, event.VideoID, event.Options)
+	}, func(err error) {
+		switch result, delay := settle(event.VideoID, err, a.lastDelivery(m.ID)); result {
+		case outcomeAck, outcomeDrop:
+			a.ack(m.ID)
+		case outcomeRetry:
+			a.retry(m.ID, delay)
+		case outcomeDeadLetter:
+			a.deadLetter(m, "max_deliver", err)
+		}
+	})
+	if err != nil {
+		log.Printf("⚠️ Event for video %d not scheduled: %v", event.VideoID, err)
+		a.retry(m.ID, 0)
+	}
+}
+
+// keepAlive resets the idle time of the entry periodically so no other
+// replica claims a job that is still running, until the returned func is called
+func (a *RedisStreamConsumerAdapter) keepAlive(id string) func() {
+	interval := a.cfg.MinIdle / 3
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				err := a.client.Do(ctx, "XCLAIM", a.cfg.Stream, a.cfg.Group, a.cfg.Consumer, 0, id, "JUSTID").Err()
+				cancel()
+				if err != nil {
+					log.Printf("⚠️ Error keeping entry %s alive: %v", id, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (a *RedisStreamConsumerAdapter) ack(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.client.XAck(ctx, a.cfg.Stream, a.cfg.Group, id).Err(); err != nil {
+		log.Printf("⚠️ Error acknowledging entry %s: %v", id, err)
+	}
+}
+
+// retry leaves the entry pending and backdates its idle time so it becomes
+// claimable once delay is over. Delays longer than MinIdle are cut to
+// MinIdle; the worker redelivers early arrivals with the remaining delay.
+func (a *RedisStreamConsumerAdapter) retry(id string, delay time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	idle := max(a.cfg.MinIdle-delay, 0)
+	err := a.client.Do(ctx, "XCLAIM", a.cfg.Stream, a.cfg.Group, a.cfg.Consumer, 0, id,
+		"IDLE", idle.Milliseconds(), "JUSTID").Err()
+	if err != nil {
+		log.Printf("⚠️ Error scheduling retry of entry %s: %v", id, err)
+	}
+}
+
+// deadLetter copies m to the dead-letter stream and acknowledges it. If the
+// copy cannot be stored m stays pending and is claimed again later.
+func (a *RedisStreamConsumerAdapter) deadLetter(m redis.XMessage, reason string, cause error) {
+	if a.cfg.DeadLetterStream == "" {
+		a.ack(m.ID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := a.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: a.cfg.DeadLetterStream,
+		Values: map[string]any{
+			redisPayloadField:         m.Values[redisPayloadField],
+			HeaderDeadLetterSubject:   a.cfg.Stream,
+			HeaderDeadLetterSequence:  m.ID,
+			HeaderDeadLetterReason:    reason,
+			HeaderDeadLetterError:     cause.Error(),
+			HeaderDeadLetterDelivered: a.deliveries(ctx, m.ID),
+			HeaderDeadLetterFailedAt:  time.Now().UTC().Format(time.RFC3339),
+		},
+	}).Err()
+	if err != nil {
+		log.Printf("❌ Error publishing to dead-letter stream %s: %v", a.cfg.DeadLetterStream, err)
+		return
+	}
+
+	log.Printf("☠️ Event moved to %s (%s): %v", a.cfg.DeadLetterStream, reason, cause)
+	eventsDeadLettered.WithLabelValues(reason).Inc()
+	a.ack(m.ID)
+}
+
+// deliveries returns how many times the entry was delivered, 0 if unknown
+func (a *RedisStreamConsumerAdapter) deliveries(ctx context.Context, id string) int64 {
+	pending, err := a.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: a.cfg.Stream,
+		Group:  a.cfg.Group,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 0
+	}
+	return pending[0].RetryCount
+}
+
+func (a *RedisStreamConsumerAdapter) lastDelivery(id string) bool {
+	if a.cfg.MaxDeliver <= 0 {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return a.deliveries(ctx, id) >= int64(a.cfg.MaxDeliver)
+}
+
+func (a *RedisStreamConsumerAdapter) Close() error {
+	return a.client.Close()
+}
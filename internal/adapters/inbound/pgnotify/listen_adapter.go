@@ -0,0 +1,166 @@
+package pgnotify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"video-processor-worker/internal/core/ports"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// Channel is notified by the videos insert trigger with the new video ID
+	Channel = "video_pending"
+
+	maxReconnectDelay = 30 * time.Second
+)
+
+// ListenAdapter picks up new videos as soon as Postgres notifies their
+// insert. Notifications are lost while the connection is down and retries
+// are never notified, so pending videos are also claimed by scan after every
+// (re)connect and every scanInterval.
+type ListenAdapter struct {
+	db           *pgxpool.Pool
+	executor     ports.JobExecutor
+	handler      ports.EventHandler
+	scan         func(ctx context.Context) (bool, error)
+	scanInterval time.Duration
+}
+
+// NewListenAdapter builds the adapter. scan claims and processes the next
+// pending video, reporting whether there was one.
+func NewListenAdapter(db *pgxpool.Pool, executor ports.JobExecutor, handler ports.EventHandler, scan func(ctx context.Context) (bool, error), scanInterval time.Duration) ports.EventConsumer {
+	return &ListenAdapter{
+		db:           db,
+		executor:     executor,
+		handler:      handler,
+		scan:         scan,
+		scanInterval: scanInterval,
+	}
+}
+
+func (a *ListenAdapter) Listen(ctx context.Context) error {
+	log.Printf("👂 Listening for Postgres notifications on channel '%s'...", Channel)
+
+	delay := time.Second
+	for ctx.Err() == nil {
+		listened, err := a.listen(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		if listened {
+			// Only consecutive failures to LISTEN back off further
+			delay = time.Second
+		}
+
+		log.Printf("⚠️ Postgres listener disconnected: %v. Reconnecting in %s...", err, delay)
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+
+	log.Println("👋 Stopped accepting Postgres notifications")
+	return nil
+}
+
+// listen holds a connection LISTENing on Channel until it fails or ctx is
+// done, reporting whether LISTEN succeeded before that
+func (a *ListenAdapter) listen(ctx context.Context) (bool, error) {
+	conn, err := a.db.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error acquiring connection: %w", err)
+	}
+	// The connection still LISTENs, so it must not go back to the pool
+	defer conn.Hijack().Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return false, fmt.Errorf("error listening on %s: %w", Channel, err)
+	}
+	log.Printf("✅ Listening on %s", Channel)
+
+	// Catch up on whatever was inserted while we were not listening
+	a.scanPending(ctx)
+	lastScan := time.Now()
+
+	for {
+		waitCtx := ctx
+		cancel := func() {}
+		if a.scanInterval > 0 {
+			waitCtx, cancel = context.WithDeadline(ctx, lastScan.Add(a.scanInterval))
+		}
+		notification, err := conn.Conn().WaitForNotification(waitCtx)
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			return true, nil
+		case err != nil && waitCtx.Err() != nil:
+			a.scanPending(ctx)
+			lastScan = time.Now()
+		case err != nil:
+			return true, err
+		default:
+			a.dispatch(ctx, notification.Payload)
+		}
+	}
+}
+
+func (a *ListenAdapter) dispatch(ctx context.Context, payload string) {
+	videoID, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		log.Printf("❌ Invalid video ID in notification: %q", payload)
+		return
+	}
+
+	log.Printf("📥 Received notification: video_id=%d", videoID)
+	err = a.executor.Submit(ctx, func(ctx context.Context) error {
+		return a.handler(ctx, videoID, nil)
+	}, func(err error) {
+		if err != nil {
+			// A retry or a missed video is picked up by the next scan
+			log.Printf("❌ Error handling notification for video %d: %v", videoID, err)
+		}
+	})
+	if err != nil {
+		log.Printf("⚠️ Notification for video %d not scheduled: %v", videoID, err)
+	}
+}
+
+// scanPending starts one claim chain per free slot. Each chain processes
+// pending videos one after another until there are none left. With every
+// slot busy it does nothing, the running chains and the next scan catch up.
+func (a *ListenAdapter) scanPending(ctx context.Context) {
+	for range a.executor.FreeSlots() {
+		a.submitScan(ctx)
+	}
+}
+
+func (a *ListenAdapter) submitScan(ctx context.Context) {
+	var found bool
+	err := a.executor.Submit(ctx, func(ctx context.Context) error {
+		var err error
+		found, err = a.scan(ctx)
+		return err
+	}, func(err error) {
+		if err != nil {
+			log.Printf("❌ Error handling video from scan: %v", err)
+		}
+		if found && ctx.Err() == nil {
+			go a.submitScan(ctx)
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("⚠️ Scan not scheduled: %v", err)
+	}
+}
+
+func (a *ListenAdapter) Close() error {
+	// The pool belongs to main, the listening connection is closed by Listen
+	return nil
+}
@@ -0,0 +1,46 @@
+package polling
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// OutboxRelayAdapter periodically ships the events waiting in the outbox
+type OutboxRelayAdapter struct {
+	interval time.Duration
+	// handler relays a batch of events, reporting how many were sent
+	handler func(ctx context.Context) (int, error)
+}
+
+func NewOutboxRelayAdapter(interval time.Duration, handler func(ctx context.Context) (int, error)) *OutboxRelayAdapter {
+	return &OutboxRelayAdapter{
+		interval: interval,
+		handler:  handler,
+	}
+}
+
+func (a *OutboxRelayAdapter) Start(ctx context.Context) {
+	log.Printf("📤 Outbox relay started, shipping events every %s...", a.interval)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("👋 Stopping outbox relay...")
+			return
+		case <-ticker.C:
+			// Keep going while batches come back non-empty
+			for ctx.Err() == nil {
+				sent, err := a.handler(ctx)
+				if err != nil {
+					log.Printf("❌ Error relaying outbox: %v", err)
+				}
+				if err != nil || sent == 0 {
+					break
+				}
+			}
+		}
+	}
+}
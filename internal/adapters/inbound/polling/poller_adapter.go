@@ -4,48 +4,181 @@ import (
 	"context"
 	"log"
 	"time"
+	"video-processor-worker/internal/core/domain"
 	"video-processor-worker/internal/core/ports"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	pollDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "worker_poller_poll_duration_seconds",
+		Help:    "Duration of the queries listing pending videos",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	pollVideosFound = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "worker_poller_videos_found_total",
+		Help: "Total number of pending videos found by the poller",
+	})
+
+	pollBacklogAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "worker_poller_backlog_age_seconds",
+		Help: "Age of the oldest pending video seen by the last poll, 0 when there was none",
+	})
 )
 
+// PollerConfig holds the tunables of the poller. The interval drops to
+// MinInterval when a poll finds videos and doubles up to MaxInterval while
+// polls come back empty.
+type PollerConfig struct {
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	PageSize    int
+}
+
+func DefaultPollerConfig() PollerConfig {
+	return PollerConfig{
+		MinInterval: time.Second,
+		MaxInterval: time.Minute,
+		PageSize:    50,
+	}
+}
+
+// PollerAdapter is the fallback for videos no event was delivered for. Only
+// the replica holding the leader lock polls; the videos it finds go through
+// the same claim-and-execute path as events.
 type PollerAdapter struct {
-	repo    ports.VideoRepository
-	handler func(ctx context.Context, videoID int64) error
+	lock     ports.LeaderLock
+	executor ports.JobExecutor
+	// list returns up to limit pending videos with an ID above afterID
+	list    func(ctx context.Context, afterID int64, limit int) ([]domain.Video, error)
+	handler ports.EventHandler
+	cfg     PollerConfig
 }
 
-func NewPollerAdapter(repo ports.VideoRepository, handler func(ctx context.Context, videoID int64) error) *PollerAdapter {
+func NewPollerAdapter(lock ports.LeaderLock, executor ports.JobExecutor,
+	list func(ctx context.Context, afterID int64, limit int) ([]domain.Video, error),
+	handler ports.EventHandler,
+	cfg PollerConfig) *PollerAdapter {
+	defaults := DefaultPollerConfig()
+	if cfg.MinInterval <= 0 {
+		cfg.MinInterval = defaults.MinInterval
+	}
+	if cfg.MaxInterval < cfg.MinInterval {
+		cfg.MaxInterval = cfg.MinInterval
+	}
+	if cfg.PageSize < 1 {
+		cfg.PageSize = defaults.PageSize
+	}
 	return &PollerAdapter{
-		repo:    repo,
-		handler: handler,
+		lock:     lock,
+		executor: executor,
+		list:     list,
+		handler:  handler,
+		cfg:      cfg,
 	}
 }
 
 func (a *PollerAdapter) Start(ctx context.Context) {
-	log.Println("🚀 Poller started, monitoring for pending videos (fallback)...")
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
+	log.Printf("🚀 Poller started, monitoring for pending videos every %s to %s (fallback)...", a.cfg.MinInterval, a.cfg.MaxInterval)
+	interval := a.cfg.MinInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	leader := false
+	defer func() {
+		if leader {
+			if err := a.lock.Release(context.WithoutCancel(ctx)); err != nil {
+				log.Printf("⚠️ Error releasing poller lock: %v", err)
+			}
+		}
+	}()
 
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("👋 Stopping poller...")
 			return
-		case <-ticker.C:
-			videos, err := a.repo.GetPending(ctx)
+		case <-timer.C:
+			acquired, err := a.lock.TryAcquire(ctx)
 			if err != nil {
-				log.Printf("❌ Error polling videos: %v", err)
-				continue
+				log.Printf("❌ Error acquiring poller lock: %v", err)
 			}
+			if acquired != leader {
+				if acquired {
+					log.Println("👑 This replica is now the poller leader")
+				} else {
+					log.Println("ℹ️ Lost poller leadership")
+				}
+				leader = acquired
+			}
+
+			found := 0
+			if leader {
+				found = a.poll(ctx)
+			}
+			interval = a.nextInterval(interval, found)
+			timer.Reset(interval)
+		}
+	}
+}
+
+// nextInterval drops back to MinInterval after a poll found videos and
+// doubles interval up to MaxInterval after an empty one
+func (a *PollerAdapter) nextInterval(interval time.Duration, found int) time.Duration {
+	if found > 0 {
+		return a.cfg.MinInterval
+	}
+	return min(interval*2, a.cfg.MaxInterval)
+}
+
+// poll submits every pending video, one page at a time, and returns how
+// many it found. Submit waits for a free slot, so the next page is only read
+// once the previous one is running.
+func (a *PollerAdapter) poll(ctx context.Context) int {
+	var afterID int64
+	found := 0
+	for ctx.Err() == nil {
+		start := time.Now()
+		videos, err := a.list(ctx, afterID, a.cfg.PageSize)
+		pollDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			log.Printf("❌ Error listing pending videos: %v", err)
+			return found
+		}
 
-			if len(videos) == 0 {
-				continue
+		pollVideosFound.Add(float64(len(videos)))
+		if afterID == 0 {
+			// Pages go by ID, so the first video is the oldest one waiting
+			age := 0.0
+			if len(videos) > 0 {
+				age = time.Since(videos[0].CreatedAt).Seconds()
 			}
+			pollBacklogAge.Set(age)
+		}
+		found += len(videos)
 
-			for _, v := range videos {
-				log.Printf("🎬 Poller found video ID: %d (%s)", v.ID, v.Filename)
-				if err := a.handler(ctx, v.ID); err != nil {
-					log.Printf("❌ Error handling video %d from poller: %v", v.ID, err)
+		for _, video := range videos {
+			videoID := video.ID
+			afterID = videoID
+			err := a.executor.Submit(ctx, func(ctx context.Context) error {
+				return a.handler(ctx, videoID, nil)
+			}, func(err error) {
+				if err != nil {
+					log.Printf("❌ Error handling video %d from poller: %v", videoID, err)
 				}
+			})
+			if err != nil {
+				log.Printf("⚠️ Video %d not scheduled: %v", videoID, err)
+				return found
 			}
 		}
+
+		if len(videos) < a.cfg.PageSize {
+			return found
+		}
 	}
+	return found
 }
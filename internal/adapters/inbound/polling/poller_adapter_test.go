@@ -0,0 +1,104 @@
+package polling
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"video-processor-worker/internal/core/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLock struct{ leader bool }
+
+func (l fakeLock) TryAcquire(ctx context.Context) (bool, error) { return l.leader, nil }
+func (l fakeLock) Release(ctx context.Context) error            { return nil }
+
+// inlineExecutor runs jobs on the caller's goroutine
+type inlineExecutor struct{}
+
+func (inlineExecutor) Submit(ctx context.Context, job func(ctx context.Context) error, done func(err error)) error {
+	done(job(ctx))
+	return nil
+}
+
+func (inlineExecutor) FreeSlots() int { return 1 }
+
+func TestPollerAdapter_NextInterval(t *testing.T) {
+	poller := NewPollerAdapter(fakeLock{}, inlineExecutor{}, nil, nil,
+		PollerConfig{MinInterval: time.Second, MaxInterval: 10 * time.Second})
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		found    int
+		next     time.Duration
+	}{
+		{"work found resets to the minimum", 8 * time.Second, 3, time.Second},
+		{"work found at the minimum", time.Second, 1, time.Second},
+		{"idle doubles", time.Second, 0, 2 * time.Second},
+		{"idle doubles again", 4 * time.Second, 0, 8 * time.Second},
+		{"idle is capped", 8 * time.Second, 0, 10 * time.Second},
+		{"idle at the cap", 10 * time.Second, 0, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.next, poller.nextInterval(tt.interval, tt.found))
+		})
+	}
+}
+
+func TestPollerAdapter_Start(t *testing.T) {
+	cfg := PollerConfig{MinInterval: 5 * time.Millisecond, MaxInterval: 40 * time.Millisecond, PageSize: 2}
+
+	run := func(leader bool, pending []int64) (polls []time.Time, handled []int64) {
+		var mu sync.Mutex
+		list := func(ctx context.Context, afterID int64, limit int) ([]domain.Video, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if afterID == 0 {
+				polls = append(polls, time.Now())
+			}
+			var videos []domain.Video
+			for _, id := range pending {
+				if id > afterID && len(videos) < limit {
+					videos = append(videos, domain.Video{ID: id})
+				}
+			}
+			return videos, nil
+		}
+		handler := func(ctx context.Context, videoID int64, opts *domain.ExtractionOptions) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, videoID)
+			pending = pending[1:]
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		NewPollerAdapter(fakeLock{leader: leader}, inlineExecutor{}, list, handler, cfg).Start(ctx)
+		return polls, handled
+	}
+
+	t.Run("leader pages through the backlog then backs off", func(t *testing.T) {
+		polls, handled := run(true, []int64{1, 2, 3})
+
+		assert.Equal(t, []int64{1, 2, 3}, handled)
+		// Doubling from the minimum needs a handful of polls to reach the
+		// cap, a fixed minimum interval would have polled about 40 times
+		assert.Less(t, len(polls), 15)
+		assert.Greater(t, len(polls), 3)
+		last := polls[len(polls)-1].Sub(polls[len(polls)-2])
+		assert.GreaterOrEqual(t, last, cfg.MaxInterval)
+	})
+
+	t.Run("other replicas do not poll", func(t *testing.T) {
+		polls, handled := run(false, []int64{1})
+
+		assert.Empty(t, polls)
+		assert.Empty(t, handled)
+	})
+}
@@ -0,0 +1,43 @@
+package polling
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ReaperAdapter periodically recovers videos abandoned in PROCESSING by a crashed worker
+type ReaperAdapter struct {
+	interval time.Duration
+	handler  func(ctx context.Context) (int, error)
+}
+
+func NewReaperAdapter(interval time.Duration, handler func(ctx context.Context) (int, error)) *ReaperAdapter {
+	return &ReaperAdapter{
+		interval: interval,
+		handler:  handler,
+	}
+}
+
+func (a *ReaperAdapter) Start(ctx context.Context) {
+	log.Printf("🧹 Reaper started, checking for abandoned videos every %s...", a.interval)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("👋 Stopping reaper...")
+			return
+		case <-ticker.C:
+			reaped, err := a.handler(ctx)
+			if err != nil {
+				log.Printf("❌ Error reaping videos: %v", err)
+				continue
+			}
+			if reaped > 0 {
+				log.Printf("🧹 Reaper recovered %d video(s)", reaped)
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package messaging
+
+import (
+	"context"
+	"log"
+	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
+)
+
+// LogEventPublisher only logs events, for environments without a broker.
+// It must not back the outbox relay, which would mark the events as sent.
+type LogEventPublisher struct{}
+
+func NewLogEventPublisher() ports.EventPublisher {
+	return &LogEventPublisher{}
+}
+
+func (p *LogEventPublisher) Publish(ctx context.Context, event domain.VideoEvent) error {
+	log.Printf("📣 [EVENT] %s video_id=%d status=%s attempt=%d", event.Type, event.VideoID, event.Status, event.Attempt)
+	return nil
+}
+
+func (p *LogEventPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,84 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
+
+	"github.com/nats-io/nats.go"
+)
+
+// eventSubjects is captured by the events stream, every event type lives under it
+const eventSubjects = "video.processing.>"
+
+type NatsEventPublisher struct {
+	nc *nats.Conn
+	js nats.JetStreamContext
+}
+
+// NewNatsEventPublisher publishes events to JetStream. With provision the
+// stream capturing video.processing.> is created when missing.
+func NewNatsEventPublisher(url, stream string, provision bool) (ports.EventPublisher, error) {
+	nc, err := nats.Connect(url)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to NATS: %w", err)
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("error getting JetStream context: %w", err)
+	}
+
+	if provision {
+		if err := ensureEventStream(js, stream); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+
+	return &NatsEventPublisher{nc: nc, js: js}, nil
+}
+
+func (p *NatsEventPublisher) Publish(ctx context.Context, event domain.VideoEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshaling event: %w", err)
+	}
+
+	msg := nats.NewMsg(event.Type)
+	msg.Data = data
+	msg.Header.Set("Content-Type", "application/json")
+	msg.Header.Set("Event-Version", fmt.Sprint(event.Version))
+
+	// The stream drops a message whose ID it has seen within its duplicate window
+	if _, err := p.js.PublishMsg(msg, nats.MsgId(event.ID()), nats.Context(ctx)); err != nil {
+		return fmt.Errorf("error publishing %s for video %d: %w", event.Type, event.VideoID, err)
+	}
+	return nil
+}
+
+func (p *NatsEventPublisher) Close() error {
+	p.nc.Close()
+	return nil
+}
+
+func ensureEventStream(js nats.JetStreamContext, stream string) error {
+	_, err := js.StreamInfo(stream)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, nats.ErrStreamNotFound) {
+		return fmt.Errorf("error fetching stream %s: %w", stream, err)
+	}
+
+	if _, err := js.AddStream(&nats.StreamConfig{Name: stream, Subjects: []string{eventSubjects}}); err != nil {
+		return fmt.Errorf("error creating stream %s: %w", stream, err)
+	}
+	log.Printf("🛠️ Created NATS stream %s (%s)", stream, eventSubjects)
+	return nil
+}
@@ -1,13 +1,26 @@
 package processor
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"video-processor-worker/internal/core/domain"
 	"video-processor-worker/internal/core/ports"
 )
 
+// sceneAttempts is how many times the scene threshold is halved to reach MinFrames
+const sceneAttempts = 3
+
 type ffmpegProcessor struct {
 	tempDir string
 }
@@ -18,35 +31,388 @@ func NewFFmpegProcessor() ports.VideoProcessor {
 	}
 }
 
-func (p *ffmpegProcessor) ExtractFrames(videoPath string, timestamp string) ([]string, error) {
+func (p *ffmpegProcessor) ExtractFrames(ctx context.Context, videoPath string, timestamp string, opts domain.ExtractionOptions, onProgress ports.ProgressFunc) (domain.ExtractionResult, error) {
 	tempOutputDir := filepath.Join(p.tempDir, timestamp)
-	os.MkdirAll(tempOutputDir, 0755)
 	// We don't remove it here because the service might need the frames for zipping
 	// Actually, the storage should probably handle temp files?
 	// For now, let's keep it simple. The service should probably be responsible for cleanup if it's not in the adapter.
 
-	framePattern := filepath.Join(tempOutputDir, "frame_%04d.png")
+	opts = opts.Normalize()
+	result := domain.ExtractionResult{Mode: opts.Mode}
+
+	var err error
+	if opts.Mode == domain.ExtractionModeScene {
+		result.FrameInfo, err = p.extractScenes(ctx, videoPath, tempOutputDir, opts, onProgress)
+		if err == nil && len(result.FrameInfo) < opts.MinFrames {
+			log.Printf("⚠️ Scene detection found %d frames (min %d), falling back to fixed-rate sampling", len(result.FrameInfo), opts.MinFrames)
+			fixed := opts
+			fixed.Mode = domain.ExtractionModeFixed
+			result.Mode = fixed.Mode
+			result.FrameInfo, err = p.run(ctx, videoPath, tempOutputDir, fixed, onProgress)
+		}
+	} else {
+		result.FrameInfo, err = p.run(ctx, videoPath, tempOutputDir, opts, onProgress)
+	}
+	if err != nil {
+		return domain.ExtractionResult{}, err
+	}
+
+	if len(result.FrameInfo) == 0 {
+		return domain.ExtractionResult{}, fmt.Errorf("%w: no frames extracted", domain.ErrInvalidVideo)
+	}
+
+	for _, frame := range result.FrameInfo {
+		result.Frames = append(result.Frames, filepath.Join(tempOutputDir, frame.Filename))
+	}
+	return result, nil
+}
+
+// extractScenes lowers the scene threshold until MinFrames is reached or the attempts run out
+func (p *ffmpegProcessor) extractScenes(ctx context.Context, videoPath, outputDir string, opts domain.ExtractionOptions, onProgress ports.ProgressFunc) ([]domain.FrameInfo, error) {
+	var frames []domain.FrameInfo
+	var err error
+	for i := 0; i < sceneAttempts; i++ {
+		frames, err = p.run(ctx, videoPath, outputDir, opts, onProgress)
+		if err != nil || len(frames) >= opts.MinFrames {
+			return frames, err
+		}
+		opts.SceneThreshold /= 2
+	}
+	return frames, nil
+}
 
-	cmd := exec.Command("ffmpeg",
-		"-i", videoPath,
-		"-vf", "fps=1",
-		"-y",
-		framePattern,
-	)
+// StreamFrames has ffmpeg write an image2pipe stream to stdout and hands
+// each image to sink, so no frame touches the disk.
+func (p *ffmpegProcessor) StreamFrames(ctx context.Context, videoPath string, opts domain.ExtractionOptions, onProgress ports.ProgressFunc, sink ports.FrameSink) (domain.ExtractionResult, error) {
+	opts = opts.Normalize()
+	result := domain.ExtractionResult{Mode: opts.Mode}
 
-	output, err := cmd.CombinedOutput()
+	var err error
+	if opts.Mode == domain.ExtractionModeScene {
+		result.FrameInfo, err = p.streamScenes(ctx, videoPath, opts, onProgress, sink)
+		if err == nil && len(result.FrameInfo) < opts.MinFrames {
+			log.Printf("⚠️ Scene detection found %d frames (min %d), falling back to fixed-rate sampling", len(result.FrameInfo), opts.MinFrames)
+			fixed := opts
+			fixed.Mode = domain.ExtractionModeFixed
+			result.Mode = fixed.Mode
+			result.FrameInfo, err = p.stream(ctx, videoPath, fixed, onProgress, func(data []byte, n int) error {
+				return sink(frameName(n, fixed), data)
+			})
+		}
+	} else {
+		result.FrameInfo, err = p.stream(ctx, videoPath, opts, onProgress, func(data []byte, n int) error {
+			return sink(frameName(n, opts), data)
+		})
+	}
 	if err != nil {
-		return nil, fmt.Errorf("ffmpeg error: %w, output: %s", err, string(output))
+		return domain.ExtractionResult{}, err
+	}
+
+	result.FrameCount = len(result.FrameInfo)
+	if result.FrameCount == 0 {
+		return domain.ExtractionResult{}, fmt.Errorf("%w: no frames extracted", domain.ErrInvalidVideo)
 	}
 
-	frames, err := filepath.Glob(filepath.Join(tempOutputDir, "*.png"))
+	return result, nil
+}
+
+// streamScenes is extractScenes for StreamFrames. A sink cannot take frames
+// back, so each attempt holds its frames in memory until MinFrames of them
+// were found, then hands them over and streams the rest. Frames of an
+// attempt that fell short never reach the sink.
+func (p *ffmpegProcessor) streamScenes(ctx context.Context, videoPath string, opts domain.ExtractionOptions, onProgress ports.ProgressFunc, sink ports.FrameSink) ([]domain.FrameInfo, error) {
+	var info []domain.FrameInfo
+	for i := 0; i < sceneAttempts; i++ {
+		buffer := newSceneBuffer(opts, sink)
+		var err error
+		if info, err = p.stream(ctx, videoPath, opts, onProgress, buffer.add); err != nil || len(info) >= opts.MinFrames {
+			return info, err
+		}
+		opts.SceneThreshold /= 2
+	}
+	return info, nil
+}
+
+// sceneBuffer holds frames back from sink until MinFrames of them were added
+type sceneBuffer struct {
+	opts    domain.ExtractionOptions
+	sink    ports.FrameSink
+	held    [][]byte
+	flushed bool
+}
+
+func newSceneBuffer(opts domain.ExtractionOptions, sink ports.FrameSink) *sceneBuffer {
+	return &sceneBuffer{opts: opts, sink: sink, flushed: opts.MinFrames <= 0}
+}
+
+// add takes the n-th frame, n counting from 1
+func (b *sceneBuffer) add(data []byte, n int) error {
+	if b.flushed {
+		return b.sink(frameName(n, b.opts), data)
+	}
+
+	b.held = append(b.held, data)
+	if len(b.held) < b.opts.MinFrames {
+		return nil
+	}
+	b.flushed = true
+	for i, held := range b.held {
+		if err := b.sink(frameName(i+1, b.opts), held); err != nil {
+			return err
+		}
+	}
+	b.held = nil
+	return nil
+}
+
+// frameName matches the names ExtractFrames gives the frame files
+func frameName(n int, opts domain.ExtractionOptions) string {
+	return fmt.Sprintf("frame_%04d.%s", n, opts.Extension())
+}
+
+// run executes ffmpeg into a clean output directory and describes the extracted frames
+func (p *ffmpegProcessor) run(ctx context.Context, videoPath, outputDir string, opts domain.ExtractionOptions, onProgress ports.ProgressFunc) ([]domain.FrameInfo, error) {
+	os.RemoveAll(outputDir)
+	os.MkdirAll(outputDir, 0755)
+
+	framePattern := filepath.Join(outputDir, "frame_%04d."+opts.Extension())
+	shown, err := p.ffmpeg(ctx, buildArgs(videoPath, opts, framePattern), opts, onProgress, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(frames) == 0 {
-		return nil, fmt.Errorf("no frames extracted")
+	files, err := filepath.Glob(filepath.Join(outputDir, "*."+opts.Extension()))
+	if err != nil {
+		return nil, err
 	}
+	sort.Strings(files)
 
+	frames := make([]domain.FrameInfo, len(files))
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		frames[i] = describeFrame(i+1, filepath.Base(file), data, shown)
+	}
 	return frames, nil
 }
+
+// stream executes ffmpeg into an image2pipe stream, hands every image to
+// emit with its 1-based number and describes them
+func (p *ffmpegProcessor) stream(ctx context.Context, videoPath string, opts domain.ExtractionOptions, onProgress ports.ProgressFunc, emit func(data []byte, n int) error) ([]domain.FrameInfo, error) {
+	// Timestamps are only known once ffmpeg is done, see describeFrame
+	var frames []domain.FrameInfo
+	shown, err := p.ffmpeg(ctx, buildArgs(videoPath, opts, "-f", "image2pipe", "pipe:1"), opts, onProgress, func(r io.Reader) error {
+		return splitFrames(r, opts.Format, func(data []byte) error {
+			n := len(frames) + 1
+			frames = append(frames, describeFrame(n, frameName(n, opts), data, nil))
+			return emit(data, n)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range frames {
+		frames[i] = withShowinfo(frames[i], shown)
+	}
+	return frames, nil
+}
+
+// describeFrame hashes the n-th frame and takes its timestamp and size from
+// the matching showinfo report, if any
+func describeFrame(n int, filename string, data []byte, shown []domain.FrameInfo) domain.FrameInfo {
+	sum := sha256.Sum256(data)
+	return withShowinfo(domain.FrameInfo{
+		Index:    n,
+		Filename: filename,
+		Size:     int64(len(data)),
+		SHA256:   hex.EncodeToString(sum[:]),
+	}, shown)
+}
+
+func withShowinfo(frame domain.FrameInfo, shown []domain.FrameInfo) domain.FrameInfo {
+	if frame.Index <= len(shown) {
+		s := shown[frame.Index-1]
+		frame.Timestamp, frame.Width, frame.Height = s.Timestamp, s.Width, s.Height
+	}
+	return frame
+}
+
+// ffmpeg runs ffmpeg with args, tracking its progress on fd 3, and returns the
+// frames showinfo reported. consume, when not nil, reads its stdout; ffmpeg is
+// killed if consume fails.
+func (p *ffmpegProcessor) ffmpeg(ctx context.Context, args []string, opts domain.ExtractionOptions, onProgress ports.ProgressFunc, consume func(r io.Reader) error) ([]domain.FrameInfo, error) {
+	cmdCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	args = append([]string{"-progress", "pipe:3", "-nostats"}, args...)
+	cmd := exec.CommandContext(cmdCtx, "ffmpeg", args...)
+
+	// stdout may carry the frames, so progress gets a pipe of its own
+	progressR, progressW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer progressR.Close()
+	cmd.ExtraFiles = []*os.File{progressW}
+
+	tracker := newProgressTracker(opts, onProgress)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		progressW.Close()
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		progressW.Close()
+		return nil, err
+	}
+	err = cmd.Start()
+	// Only ffmpeg holds the write end now, so the reader sees EOF when it exits
+	progressW.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error starting ffmpeg: %w", err)
+	}
+
+	if consume == nil {
+		consume = func(r io.Reader) error {
+			_, err := io.Copy(io.Discard, r)
+			return err
+		}
+	}
+
+	// Every pipe must be drained before Wait closes them
+	var consumeErr error
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		tracker.watchProgress(progressR)
+	}()
+	go func() {
+		defer wg.Done()
+		tracker.watchStderr(stderr)
+	}()
+	go func() {
+		defer wg.Done()
+		if consumeErr = consume(stdout); consumeErr != nil {
+			cancel()
+			io.Copy(io.Discard, stdout)
+		}
+	}()
+	wg.Wait()
+
+	err = cmd.Wait()
+	// An ffmpeg that exited on its own cut its output short, while one that
+	// was killed for a consume error did not fail
+	exited := cmd.ProcessState != nil && cmd.ProcessState.Exited()
+	switch {
+	case err == nil && consumeErr == nil:
+		return tracker.frames(), nil
+	case ctx.Err() != nil:
+		// A killed ffmpeg only says "signal: killed", report why it was killed instead
+		return nil, fmt.Errorf("ffmpeg interrupted: %w", ctx.Err())
+	case consumeErr != nil && (err == nil || !exited):
+		return nil, fmt.Errorf("error handling ffmpeg output: %w", consumeErr)
+	default:
+		return nil, ffmpegError(err, tracker.output())
+	}
+}
+
+// undecodable are the stderr messages ffmpeg prints when the input itself is broken
+var undecodable = []string{
+	"Invalid data found when processing input",
+	"moov atom not found",
+	"error while decoding",
+	"Invalid NAL unit size",
+	"could not find codec parameters",
+	"decode_slice_header error",
+}
+
+// ffmpegError marks a failed run as ErrInvalidVideo only when stderr blames the
+// input, anything else (full disk, I/O errors, a killed process) is retryable
+func ffmpegError(err error, output string) error {
+	lower := strings.ToLower(output)
+	for _, msg := range undecodable {
+		if strings.Contains(lower, strings.ToLower(msg)) {
+			return fmt.Errorf("%w: ffmpeg error: %v, output: %s", domain.ErrInvalidVideo, err, output)
+		}
+	}
+	return fmt.Errorf("ffmpeg error: %v, output: %s", err, output)
+}
+
+func buildArgs(videoPath string, opts domain.ExtractionOptions, output ...string) []string {
+	var args []string
+
+	// Seeking before -i is fast and makes -t relative to the start time
+	if opts.StartTime > 0 {
+		args = append(args, "-ss", formatSeconds(opts.StartTime))
+	}
+	args = append(args, "-i", videoPath)
+	if opts.EndTime > 0 {
+		args = append(args, "-t", formatSeconds(opts.EndTime-opts.StartTime))
+	}
+
+	filters := []string{fpsFilter(opts)}
+	if opts.Mode == domain.ExtractionModeScene {
+		// Always keep the first frame so the opening shot is represented
+		filters[0] = fmt.Sprintf("select='eq(n,0)+gt(scene,%s)'", strconv.FormatFloat(opts.SceneThreshold, 'f', 4, 64))
+	}
+	if scale := scaleFilter(opts); scale != "" {
+		filters = append(filters, scale)
+	}
+	// showinfo logs the timestamp and size of every output frame for the manifest
+	filters = append(filters, "showinfo")
+	args = append(args, "-vf", strings.Join(filters, ","))
+	if opts.Mode == domain.ExtractionModeScene {
+		args = append(args, "-fps_mode", "vfr")
+	}
+
+	if opts.MaxFrames > 0 {
+		args = append(args, "-frames:v", strconv.Itoa(opts.MaxFrames))
+	}
+
+	args = append(args, codecArgs(opts)...)
+
+	args = append(args, "-y")
+	return append(args, output...)
+}
+
+// scaleFilter shrinks frames to fit MaxWidth x MaxHeight keeping the aspect ratio, never upscaling
+func scaleFilter(opts domain.ExtractionOptions) string {
+	switch {
+	case opts.MaxWidth > 0 && opts.MaxHeight > 0:
+		return fmt.Sprintf("scale='min(iw,%d)':'min(ih,%d)':force_original_aspect_ratio=decrease", opts.MaxWidth, opts.MaxHeight)
+	case opts.MaxWidth > 0:
+		return fmt.Sprintf("scale='min(iw,%d)':-1", opts.MaxWidth)
+	case opts.MaxHeight > 0:
+		return fmt.Sprintf("scale=-1:'min(ih,%d)'", opts.MaxHeight)
+	}
+	return ""
+}
+
+func codecArgs(opts domain.ExtractionOptions) []string {
+	switch opts.Format {
+	case domain.ImageFormatJPEG:
+		// mjpeg uses a 2 (best) to 31 (worst) scale
+		q := 2 + (100-opts.Quality)*29/99
+		return []string{"-c:v", "mjpeg", "-q:v", strconv.Itoa(q)}
+	case domain.ImageFormatWebP:
+		return []string{"-c:v", "libwebp", "-quality", strconv.Itoa(opts.Quality)}
+	}
+	// image2pipe does not pick the codec from a file extension
+	return []string{"-c:v", "png"}
+}
+
+func fpsFilter(opts domain.ExtractionOptions) string {
+	if opts.Interval > 0 {
+		return "fps=1/" + formatSeconds(opts.Interval)
+	}
+	return "fps=" + strconv.FormatFloat(opts.FPS, 'f', -1, 64)
+}
+
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64)
+}
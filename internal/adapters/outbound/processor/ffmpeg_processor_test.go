@@ -0,0 +1,55 @@
+package processor
+
+import (
+	"errors"
+	"testing"
+	"video-processor-worker/internal/core/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFFmpegError(t *testing.T) {
+	exit := errors.New("exit status 1")
+
+	tests := []struct {
+		name      string
+		output    string
+		permanent bool
+	}{
+		{"corrupt container", "[mov,mp4 @ 0x55] moov atom not found\nvideo.mp4: Invalid data found when processing input", true},
+		{"broken stream", "[h264 @ 0x55] Error while decoding stream #0:0: Invalid data found", true},
+		{"disk full", "[image2 @ 0x55] Could not write frame: No space left on device", false},
+		{"io error", "av_interleaved_write_frame(): Input/output error", false},
+		{"no output", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ffmpegError(exit, tt.output)
+
+			assert.Equal(t, tt.permanent, errors.Is(err, domain.ErrInvalidVideo))
+			assert.Equal(t, tt.permanent, domain.IsPermanent(err))
+			assert.Contains(t, err.Error(), "exit status 1")
+		})
+	}
+}
+
+func TestSceneBuffer(t *testing.T) {
+	add := func(minFrames, frames int) []string {
+		var sunk []string
+		opts := domain.ExtractionOptions{Format: domain.ImageFormatPNG, MinFrames: minFrames}
+		buffer := newSceneBuffer(opts, func(name string, data []byte) error {
+			sunk = append(sunk, name)
+			return nil
+		})
+		for n := 1; n <= frames; n++ {
+			assert.NoError(t, buffer.add([]byte{byte(n)}, n))
+			assert.LessOrEqual(t, len(buffer.held), max(minFrames-1, 0), "holds at most MinFrames-1 frames")
+		}
+		return sunk
+	}
+
+	assert.Equal(t, []string{"frame_0001.png", "frame_0002.png"}, add(0, 2), "streams straight through without MinFrames")
+	assert.Empty(t, add(3, 2), "holds frames until MinFrames is reached")
+	assert.Equal(t, []string{"frame_0001.png", "frame_0002.png", "frame_0003.png", "frame_0004.png"}, add(3, 4))
+}
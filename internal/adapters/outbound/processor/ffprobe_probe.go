@@ -0,0 +1,148 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
+)
+
+type ffprobeProbe struct{}
+
+func NewFFprobeProbe() ports.VideoProbe {
+	return &ffprobeProbe{}
+}
+
+type ffprobeStream struct {
+	CodecType    string `json:"codec_type"`
+	CodecName    string `json:"codec_name"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	AvgFrameRate string `json:"avg_frame_rate"`
+	RFrameRate   string `json:"r_frame_rate"`
+	Duration     string `json:"duration"`
+	Channels     int    `json:"channels"`
+	SampleRate   string `json:"sample_rate"`
+	Tags         struct {
+		Rotate string `json:"rotate"`
+	} `json:"tags"`
+	SideDataList []struct {
+		Rotation int `json:"rotation"`
+	} `json:"side_data_list"`
+}
+
+type ffprobeOutput struct {
+	Streams []ffprobeStream `json:"streams"`
+	Format  struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+	} `json:"format"`
+}
+
+func (p *ffprobeProbe) Probe(ctx context.Context, videoPath string) (*domain.VideoMetadata, error) {
+	info, err := os.Stat(videoPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, fmt.Errorf("%w: empty file", domain.ErrInvalidVideo)
+	}
+
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		videoPath,
+	)
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: ffprobe error: %v, output: %s", domain.ErrInvalidVideo, err, stderr.String())
+	}
+
+	var out ffprobeOutput
+	if err := json.Unmarshal(output, &out); err != nil {
+		return nil, fmt.Errorf("error parsing ffprobe output: %w", err)
+	}
+
+	metadata := &domain.VideoMetadata{
+		Container: out.Format.FormatName,
+		Duration:  parseFloat(out.Format.Duration),
+		Size:      info.Size(),
+	}
+
+	for _, s := range out.Streams {
+		switch s.CodecType {
+		case "video":
+			if metadata.Codec != "" {
+				continue // only the first video stream is extracted
+			}
+			metadata.Codec = s.CodecName
+			metadata.Width = s.Width
+			metadata.Height = s.Height
+			metadata.FrameRate = parseRate(s.AvgFrameRate)
+			if metadata.FrameRate == 0 {
+				metadata.FrameRate = parseRate(s.RFrameRate)
+			}
+			metadata.Rotation = rotation(s)
+			if metadata.Duration == 0 {
+				metadata.Duration = parseFloat(s.Duration)
+			}
+		case "audio":
+			sampleRate, _ := strconv.Atoi(s.SampleRate)
+			metadata.AudioStreams = append(metadata.AudioStreams, domain.AudioStream{
+				Codec:      s.CodecName,
+				Channels:   s.Channels,
+				SampleRate: sampleRate,
+			})
+		}
+	}
+
+	return metadata, nil
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// parseRate parses ffprobe rationals such as "30000/1001"
+func parseRate(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		return parseFloat(s)
+	}
+	d := parseFloat(den)
+	if d == 0 {
+		return 0
+	}
+	return parseFloat(num) / d
+}
+
+// rotation reads the legacy rotate tag or the display matrix side data, normalised to 0-359 clockwise
+func rotation(s ffprobeStream) int {
+	var deg int
+	if s.Tags.Rotate != "" {
+		deg, _ = strconv.Atoi(s.Tags.Rotate)
+	} else {
+		for _, sd := range s.SideDataList {
+			if sd.Rotation != 0 {
+				// The display matrix rotation is counter-clockwise
+				deg = -sd.Rotation
+				break
+			}
+		}
+	}
+	return ((deg % 360) + 360) % 360
+}
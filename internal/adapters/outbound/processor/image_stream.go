@@ -0,0 +1,155 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"video-processor-worker/internal/core/domain"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// splitFrames cuts an image2pipe stream into its images and hands each one
+// to emit. ffmpeg writes the images back to back without any framing, so
+// the end of each one is found by walking its container format.
+func splitFrames(r io.Reader, format string, emit func(data []byte) error) error {
+	br := bufio.NewReaderSize(r, 256*1024)
+
+	var next func(*bufio.Reader) ([]byte, error)
+	switch format {
+	case domain.ImageFormatJPEG:
+		next = readJPEG
+	case domain.ImageFormatWebP:
+		next = readWebP
+	default:
+		next = readPNG
+	}
+
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			return nil
+		}
+		data, err := next(br)
+		if err != nil {
+			return err
+		}
+		if err := emit(data); err != nil {
+			return err
+		}
+	}
+}
+
+// readPNG reads the signature and every chunk up to and including IEND
+func readPNG(br *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, br, int64(len(pngSignature))); err != nil {
+		return nil, truncated(err)
+	}
+	if !bytes.Equal(buf.Bytes(), pngSignature) {
+		return nil, errors.New("invalid PNG signature in frame stream")
+	}
+
+	for {
+		// length, type, data, CRC
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(br, header); err != nil {
+			return nil, truncated(err)
+		}
+		buf.Write(header)
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		if _, err := io.CopyN(&buf, br, length+4); err != nil {
+			return nil, truncated(err)
+		}
+		if string(header[4:]) == "IEND" {
+			return buf.Bytes(), nil
+		}
+	}
+}
+
+// readJPEG reads the segments up to the scan, then the entropy-coded data
+// up to the EOI marker. A 0xFF byte inside the scan is always followed by
+// 0x00 or a restart marker, so the first other marker ends the image.
+func readJPEG(br *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(br, soi); err != nil {
+		return nil, truncated(err)
+	}
+	if soi[0] != 0xFF || soi[1] != 0xD8 {
+		return nil, errors.New("invalid JPEG start in frame stream")
+	}
+	buf.Write(soi)
+
+	inScan := false
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, truncated(err)
+		}
+		buf.WriteByte(b)
+		if b != 0xFF {
+			if !inScan {
+				return nil, fmt.Errorf("invalid JPEG marker 0x%02x in frame stream", b)
+			}
+			continue
+		}
+
+		// Any number of 0xFF fill bytes may precede a marker
+		marker, err := br.ReadByte()
+		for err == nil && marker == 0xFF {
+			buf.WriteByte(marker)
+			marker, err = br.ReadByte()
+		}
+		if err != nil {
+			return nil, truncated(err)
+		}
+		buf.WriteByte(marker)
+		switch {
+		case marker == 0xD9:
+			return buf.Bytes(), nil
+		case marker == 0x00, marker >= 0xD0 && marker <= 0xD7:
+			// Stuffed byte or restart marker
+			continue
+		}
+
+		// Every other marker carries a length that includes itself
+		size := make([]byte, 2)
+		if _, err := io.ReadFull(br, size); err != nil {
+			return nil, truncated(err)
+		}
+		buf.Write(size)
+		if _, err := io.CopyN(&buf, br, int64(binary.BigEndian.Uint16(size))-2); err != nil {
+			return nil, truncated(err)
+		}
+		inScan = marker == 0xDA
+	}
+}
+
+// readWebP reads a RIFF container, whose header holds its size
+func readWebP(br *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, truncated(err)
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:]) != "WEBP" {
+		return nil, errors.New("invalid WebP header in frame stream")
+	}
+
+	// The RIFF size counts everything after itself, "WEBP" included
+	size := int64(binary.LittleEndian.Uint32(header[4:8])) - 4
+	buf := bytes.NewBuffer(header)
+	if _, err := io.CopyN(buf, br, size); err != nil {
+		return nil, truncated(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func truncated(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("truncated frame in stream: %w", err)
+}
@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+	"video-processor-worker/internal/core/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testImage(shade uint8) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			img.Set(x, y, color.RGBA{shade, uint8(x * 16), uint8(y * 16), 255})
+		}
+	}
+	return img
+}
+
+// fakeWebP is a RIFF container with a single odd-sized chunk
+func fakeWebP(payload string) []byte {
+	chunk := []byte("VP8L")
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+
+	data := []byte("RIFF")
+	data = binary.LittleEndian.AppendUint32(data, uint32(4+len(chunk)))
+	data = append(data, "WEBP"...)
+	return append(data, chunk...)
+}
+
+func TestSplitFrames(t *testing.T) {
+	encode := map[string]func(w io.Writer, img image.Image) error{
+		domain.ImageFormatPNG: png.Encode,
+		domain.ImageFormatJPEG: func(w io.Writer, img image.Image) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+		},
+	}
+
+	for format, enc := range encode {
+		t.Run(format+" stream is cut into its images", func(t *testing.T) {
+			var stream bytes.Buffer
+			var want [][]byte
+			for shade := uint8(0); shade < 3; shade++ {
+				var frame bytes.Buffer
+				require.NoError(t, enc(&frame, testImage(shade*100)))
+				want = append(want, frame.Bytes())
+				stream.Write(frame.Bytes())
+			}
+
+			var got [][]byte
+			err := splitFrames(&stream, format, func(data []byte) error {
+				got = append(got, data)
+				return nil
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+
+	t.Run("webp stream is cut into its images", func(t *testing.T) {
+		want := [][]byte{fakeWebP("abc"), fakeWebP("defg")}
+		stream := bytes.NewReader(append(append([]byte{}, want[0]...), want[1]...))
+
+		var got [][]byte
+		err := splitFrames(stream, domain.ImageFormatWebP, func(data []byte) error {
+			got = append(got, data)
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("truncated image is an error", func(t *testing.T) {
+		var frame bytes.Buffer
+		require.NoError(t, png.Encode(&frame, testImage(0)))
+
+		err := splitFrames(bytes.NewReader(frame.Bytes()[:frame.Len()-10]), domain.ImageFormatPNG, func(data []byte) error {
+			return nil
+		})
+
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("empty stream has no images", func(t *testing.T) {
+		called := false
+		err := splitFrames(bytes.NewReader(nil), domain.ImageFormatJPEG, func(data []byte) error {
+			called = true
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.False(t, called)
+	})
+}
@@ -0,0 +1,190 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
+)
+
+// maxStderr bounds how much ffmpeg output is kept for error messages
+const maxStderr = 16 * 1024
+
+var (
+	durationRe  = regexp.MustCompile(`Duration: (\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+	showinfoRe  = regexp.MustCompile(`\[Parsed_showinfo_\d+ @ [^\]]+\] n:\s*(\d+)\s+pts:\s*\S+\s+pts_time:(\S+)`)
+	frameSizeRe = regexp.MustCompile(`\ss:(\d+)x(\d+)\s`)
+)
+
+// progressTracker turns ffmpeg's "-progress pipe:3" key=value blocks, read
+// from the pipe passed in through ExtraFiles, into domain.Progress updates.
+// The total is taken from the input "Duration:" line ffmpeg prints on
+// stderr, narrowed to the selected range.
+type progressTracker struct {
+	opts       domain.ExtractionOptions
+	onProgress ports.ProgressFunc
+	started    time.Time
+
+	mu     sync.Mutex
+	total  float64 // seconds that will be decoded, 0 while unknown
+	stderr bytes.Buffer
+	shown  []domain.FrameInfo // frames reported by the showinfo filter
+}
+
+func newProgressTracker(opts domain.ExtractionOptions, onProgress ports.ProgressFunc) *progressTracker {
+	return &progressTracker{
+		opts:       opts,
+		onProgress: onProgress,
+		started:    time.Now(),
+	}
+}
+
+// watchStderr keeps the tail of stderr for error messages and picks up the
+// input duration and the frames reported by showinfo
+func (t *progressTracker) watchStderr(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// showinfo logs a few lines per frame, which would crowd the errors out
+		if strings.Contains(line, "Parsed_showinfo_") {
+			t.showinfo(line)
+			continue
+		}
+
+		t.mu.Lock()
+		if t.total == 0 {
+			if m := durationRe.FindStringSubmatch(line); m != nil {
+				t.total = t.selected(parseClock(m[1], m[2], m[3]))
+			}
+		}
+		t.stderr.WriteString(line)
+		t.stderr.WriteByte('\n')
+		if t.stderr.Len() > maxStderr {
+			t.stderr.Next(t.stderr.Len() - maxStderr)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// showinfo records the frame a showinfo line describes. Timestamps are made
+// relative to the start of the source, as seeking resets them to zero.
+func (t *progressTracker) showinfo(line string) {
+	m := showinfoRe.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+	n, _ := strconv.Atoi(m[1])
+	ptsTime, _ := strconv.ParseFloat(m[2], 64)
+
+	frame := domain.FrameInfo{Index: n + 1, Timestamp: ptsTime + t.opts.StartTime}
+	if s := frameSizeRe.FindStringSubmatch(line); s != nil {
+		frame.Width, _ = strconv.Atoi(s[1])
+		frame.Height, _ = strconv.Atoi(s[2])
+	}
+
+	t.mu.Lock()
+	t.shown = append(t.shown, frame)
+	t.mu.Unlock()
+}
+
+// frames returns the frames reported by showinfo, in output order
+func (t *progressTracker) frames() []domain.FrameInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]domain.FrameInfo(nil), t.shown...)
+}
+
+func (t *progressTracker) output() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stderr.String()
+}
+
+// watchProgress emits one update per block, blocks end with a "progress=" line
+func (t *progressTracker) watchProgress(r io.Reader) {
+	var frames int
+	var outTime, speed float64
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "frame":
+			frames, _ = strconv.Atoi(value)
+		case "out_time_us":
+			if us, err := strconv.ParseFloat(value, 64); err == nil && us > 0 {
+				outTime = us / 1e6
+			}
+		case "speed":
+			speed, _ = strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64)
+		case "progress":
+			if t.onProgress != nil {
+				t.onProgress(t.progress(frames, outTime, speed, value == "end"))
+			}
+		}
+	}
+}
+
+func (t *progressTracker) progress(frames int, outTime, speed float64, done bool) domain.Progress {
+	t.mu.Lock()
+	total := t.total
+	t.mu.Unlock()
+
+	p := domain.Progress{Frames: frames}
+	switch {
+	case done:
+		p.Percent = 100
+	case total > 0:
+		p.Percent = min(outTime/total*100, 99.9)
+	}
+
+	if !done && total > 0 && outTime > 0 {
+		remaining := total - outTime
+		if speed > 0 {
+			p.ETASeconds = remaining / speed
+		} else {
+			p.ETASeconds = time.Since(t.started).Seconds() / outTime * remaining
+		}
+		p.ETASeconds = max(p.ETASeconds, 0)
+	}
+	return p
+}
+
+// selected narrows the input duration to the StartTime/EndTime range
+func (t *progressTracker) selected(duration float64) float64 {
+	end := duration
+	if t.opts.EndTime > 0 && t.opts.EndTime < end {
+		end = t.opts.EndTime
+	}
+	return max(end-t.opts.StartTime, 0)
+}
+
+func parseClock(h, m, s string) float64 {
+	hours, _ := strconv.ParseFloat(h, 64)
+	minutes, _ := strconv.ParseFloat(m, 64)
+	seconds, _ := strconv.ParseFloat(s, 64)
+	return hours*3600 + minutes*60 + seconds
+}
+
+// scanLines splits on \n and on the \r ffmpeg uses to redraw its status line
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+	"video-processor-worker/internal/core/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressTracker_Showinfo(t *testing.T) {
+	stderr := strings.Join([]string{
+		"  Duration: 00:00:10.00, start: 0.000000, bitrate: 1205 kb/s",
+		"[Parsed_showinfo_1 @ 0x5581c0] config in time_base: 1/1, frame_rate: 1/1",
+		"[Parsed_showinfo_1 @ 0x5581c0] n:   0 pts:      0 pts_time:0       duration:      1 duration_time:1       fmt:rgb24 sar:1/1 s:320x240 i:P iskey:1 type:I checksum:8C5E2E41",
+		"[Parsed_showinfo_1 @ 0x5581c0] color_range:pc color_space:gbr color_primaries:unknown",
+		"[Parsed_showinfo_1 @ 0x5581c0] n:   1 pts:      1 pts_time:1.5     duration:      1 duration_time:1       fmt:rgb24 sar:1/1 s:320x240 i:P iskey:0 type:P checksum:1D0F3A52",
+		"[out#0/image2 @ 0x5581c1] video:24kB audio:0kB",
+	}, "\n")
+
+	tracker := newProgressTracker(domain.ExtractionOptions{StartTime: 2}, nil)
+	tracker.watchStderr(strings.NewReader(stderr))
+
+	assert.Equal(t, []domain.FrameInfo{
+		{Index: 1, Timestamp: 2, Width: 320, Height: 240},
+		{Index: 2, Timestamp: 3.5, Width: 320, Height: 240},
+	}, tracker.frames())
+	assert.NotContains(t, tracker.output(), "showinfo")
+	assert.Contains(t, tracker.output(), "Duration: 00:00:10.00")
+}
+
+func TestProgressTracker_Progress(t *testing.T) {
+	block := func(lines ...string) string {
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	tests := []struct {
+		name     string
+		opts     domain.ExtractionOptions
+		stderr   string
+		progress string
+		want     []domain.Progress
+	}{
+		{
+			name:   "percent and ETA from out_time and speed",
+			stderr: "  Duration: 00:00:10.00, start: 0.000000, bitrate: 1205 kb/s",
+			progress: block("frame=5", "out_time_us=2500000", "out_time=00:00:02.500000", "speed=2.5x", "progress=continue") +
+				block("frame=20", "out_time_us=10000000", "speed=2x", "progress=end"),
+			want: []domain.Progress{
+				{Percent: 25, Frames: 5, ETASeconds: 3},
+				{Percent: 100, Frames: 20},
+			},
+		},
+		{
+			name:   "duration narrowed to the selected range",
+			opts:   domain.ExtractionOptions{StartTime: 2, EndTime: 6},
+			stderr: "  Duration: 00:00:10.00, start: 0.000000, bitrate: 1205 kb/s",
+			progress: block("frame=3", "out_time_us=1000000", "speed=1x", "progress=continue") +
+				block("frame=9", "out_time_us=5000000", "speed=1x", "progress=continue"),
+			want: []domain.Progress{
+				{Percent: 25, Frames: 3, ETASeconds: 3},
+				{Percent: 99.9, Frames: 9},
+			},
+		},
+		{
+			name:   "missing duration only reports frames",
+			stderr: "video.mp4: Invalid duration",
+			progress: block("frame=5", "out_time_us=2500000", "speed=1x", "progress=continue") +
+				block("frame=8", "out_time_us=4000000", "speed=1x", "progress=end"),
+			want: []domain.Progress{
+				{Frames: 5},
+				{Percent: 100, Frames: 8},
+			},
+		},
+		{
+			name:     "out_time not known yet",
+			stderr:   "  Duration: 00:01:00.00, start: 0.000000, bitrate: 1205 kb/s",
+			progress: block("frame=0", "out_time_us=N/A", "out_time=N/A", "speed=N/A", "progress=continue"),
+			want:     []domain.Progress{{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []domain.Progress
+			tracker := newProgressTracker(tt.opts, func(p domain.Progress) { got = append(got, p) })
+			tracker.watchStderr(strings.NewReader(tt.stderr))
+			tracker.watchProgress(strings.NewReader(tt.progress))
+
+			require.Len(t, got, len(tt.want))
+			for i, want := range tt.want {
+				assert.InDelta(t, want.Percent, got[i].Percent, 0.001, "percent of update %d", i)
+				assert.InDelta(t, want.ETASeconds, got[i].ETASeconds, 0.001, "ETA of update %d", i)
+				assert.Equal(t, want.Frames, got[i].Frames, "frames of update %d", i)
+			}
+		})
+	}
+}
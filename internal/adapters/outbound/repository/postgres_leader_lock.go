@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"video-processor-worker/internal/core/ports"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresLeaderLock is a session advisory lock. The session holding it is
+// kept out of the pool, and the lock goes away with it if the replica dies.
+type postgresLeaderLock struct {
+	db  *pgxpool.Pool
+	key int64
+
+	mu   sync.Mutex
+	conn *pgxpool.Conn
+}
+
+func NewPostgresLeaderLock(db *pgxpool.Pool, key int64) ports.LeaderLock {
+	return &postgresLeaderLock{
+		db:  db,
+		key: key,
+	}
+}
+
+func (l *postgresLeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		// Still the leader as long as the session that holds the lock is alive
+		if err := l.conn.Ping(ctx); err == nil {
+			return true, nil
+		}
+		l.drop(ctx)
+	}
+
+	conn, err := l.db.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&acquired); err != nil {
+		conn.Release()
+		return false, err
+	}
+	if !acquired {
+		conn.Release()
+		return false, nil
+	}
+
+	l.conn = conn
+	return true, nil
+}
+
+func (l *postgresLeaderLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	if _, err := l.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		l.drop(ctx)
+		return err
+	}
+	l.conn.Release()
+	l.conn = nil
+	return nil
+}
+
+// drop closes the session instead of returning it to the pool, which also
+// frees the lock if the session was still holding it
+func (l *postgresLeaderLock) drop(ctx context.Context) {
+	l.conn.Hijack().Close(context.WithoutCancel(ctx))
+	l.conn = nil
+}
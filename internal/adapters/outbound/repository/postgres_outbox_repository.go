@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type postgresOutboxRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresOutboxRepository(db *pgxpool.Pool) ports.OutboxRepository {
+	return &postgresOutboxRepository{
+		db: db,
+	}
+}
+
+func (r *postgresOutboxRepository) Add(ctx context.Context, event domain.VideoEvent) error {
+	query := `INSERT INTO outbox (event_id, event_type, payload) VALUES ($1, $2, $3)`
+	_, err := conn(ctx, r.db).Exec(ctx, query, event.ID(), event.Type, event)
+	return err
+}
+
+func (r *postgresOutboxRepository) ClaimUnsent(ctx context.Context, limit int) ([]domain.OutboxEntry, error) {
+	query := `
+		SELECT id, payload, attempts, created_at
+		FROM outbox o
+		WHERE sent_at IS NULL AND abandoned_at IS NULL
+			AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox waiting
+				WHERE waiting.payload->>'video_id' = o.payload->>'video_id' AND waiting.id < o.id
+					AND waiting.sent_at IS NULL AND waiting.abandoned_at IS NULL AND waiting.next_attempt_at > NOW()
+			)
+		ORDER BY id ASC
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+	rows, err := conn(ctx, r.db).Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []domain.OutboxEntry
+	for rows.Next() {
+		var e domain.OutboxEntry
+		if err := rows.Scan(&e.ID, &e.Event, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (r *postgresOutboxRepository) MarkSent(ctx context.Context, id int64) error {
+	query := `UPDATE outbox SET sent_at = NOW() WHERE id = $1`
+	_, err := conn(ctx, r.db).Exec(ctx, query, id)
+	return err
+}
+
+func (r *postgresOutboxRepository) MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`
+	_, err := conn(ctx, r.db).Exec(ctx, query, id, reason, retryAt)
+	return err
+}
+
+func (r *postgresOutboxRepository) Abandon(ctx context.Context, id int64, reason string) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, last_error = $2, abandoned_at = NOW() WHERE id = $1`
+	_, err := conn(ctx, r.db).Exec(ctx, query, id, reason)
+	return err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"video-processor-worker/internal/core/ports"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type txKey struct{}
+
+// querier is the part of pgxpool.Pool and pgx.Tx the repositories use
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// conn returns the transaction started by WithinTx, if any, or the pool
+func conn(ctx context.Context, db *pgxpool.Pool) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+type postgresTxManager struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresTxManager(db *pgxpool.Pool) ports.TxManager {
+	return &postgresTxManager{
+		db: db,
+	}
+}
+
+func (m *postgresTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	// Nested calls join the outer transaction
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
@@ -2,13 +2,21 @@ package repository
 
 import (
 	"context"
+	"time"
 	"video-processor-worker/internal/core/domain"
 	"video-processor-worker/internal/core/ports"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const videoColumns = `id, user_id, filename, status, COALESCE(zip_path, ''), frame_count, COALESCE(message, ''),
+	COALESCE(extraction_options, '{}'::jsonb), COALESCE(extraction_mode, ''),
+	COALESCE(archive_format, ''), COALESCE(archive_extension, ''), COALESCE(archive_size, 0), COALESCE(archive_sha256, ''), metadata,
+	progress_percent, progress_frames, progress_eta_seconds, attempts, heartbeat_at,
+	COALESCE(worker_id, ''), claimed_at, COALESCE(last_error, ''), next_attempt_at, created_at, updated_at`
+
 type postgresVideoRepository struct {
 	db *pgxpool.Pool
 }
@@ -19,32 +27,137 @@ func NewPostgresVideoRepository(db *pgxpool.Pool) ports.VideoRepository {
 	}
 }
 
+func scanVideo(row pgx.Row, video *domain.Video) error {
+	return row.Scan(&video.ID, &video.UserID, &video.Filename, &video.Status, &video.ZipPath, &video.FrameCount, &video.Message,
+		&video.Options, &video.ExtractionMode, &video.ArchiveFormat, &video.ArchiveExt,
+		&video.ArchiveSize, &video.ArchiveSHA256, &video.Metadata,
+		&video.Progress.Percent, &video.Progress.Frames, &video.Progress.ETASeconds, &video.Attempts, &video.HeartbeatAt,
+		&video.WorkerID, &video.ClaimedAt, &video.LastError, &video.NextAttemptAt, &video.CreatedAt, &video.UpdatedAt)
+}
+
 func (r *postgresVideoRepository) Update(ctx context.Context, video *domain.Video) error {
 	query := `
 		UPDATE videos
-		SET status = $1, zip_path = $2, frame_count = $3, message = $4, updated_at = NOW()
-		WHERE id = $5
+		SET status = $1, zip_path = $2, frame_count = $3, message = $4, extraction_options = $5, extraction_mode = $6,
+			metadata = $7, progress_percent = $8, progress_frames = $9, progress_eta_seconds = $10,
+			attempts = $11, last_error = $12, next_attempt_at = $13,
+			archive_format = $14, archive_extension = $15, archive_size = $16, archive_sha256 = $17, updated_at = NOW()
+		WHERE id = $18 AND status = 'PROCESSING' AND worker_id = $19
 		RETURNING updated_at
 	`
-	err := r.db.QueryRow(ctx, query, video.Status, video.ZipPath, video.FrameCount, video.Message, video.ID).
+	err := conn(ctx, r.db).QueryRow(ctx, query, video.Status, video.ZipPath, video.FrameCount, video.Message, video.Options,
+		video.ExtractionMode, video.Metadata, video.Progress.Percent, video.Progress.Frames, video.Progress.ETASeconds,
+		video.Attempts, video.LastError, video.NextAttemptAt, video.ArchiveFormat, video.ArchiveExt, video.ArchiveSize, video.ArchiveSHA256, video.ID, video.WorkerID).
 		Scan(&video.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return domain.ErrLostOwnership
+	}
 	return err
 }
 
+func (r *postgresVideoRepository) UpdateProgress(ctx context.Context, id int64, workerID string, progress domain.Progress) error {
+	query := `
+		UPDATE videos
+		SET progress_percent = $1, progress_frames = $2, progress_eta_seconds = $3, updated_at = NOW()
+		WHERE id = $4 AND status = 'PROCESSING' AND worker_id = $5
+	`
+	tag, err := conn(ctx, r.db).Exec(ctx, query, progress.Percent, progress.Frames, progress.ETASeconds, id, workerID)
+	return owned(tag, err)
+}
+
+// owned turns an update that matched no row into domain.ErrLostOwnership
+func owned(tag pgconn.CommandTag, err error) error {
+	if err == nil && tag.RowsAffected() == 0 {
+		return domain.ErrLostOwnership
+	}
+	return err
+}
+
+// claimSet is the SET clause shared by Claim and ClaimNextPending, $1 is the worker ID
+const claimSet = `
+	SET status = 'PROCESSING', message = 'Processamento iniciado...', worker_id = $1, claimed_at = NOW(),
+		heartbeat_at = NOW(), attempts = attempts + 1, next_attempt_at = NULL, updated_at = NOW()`
+
+// due is true for PENDING rows whose retry backoff, if any, is over
+const due = `status = 'PENDING' AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())`
+
+func (r *postgresVideoRepository) Claim(ctx context.Context, id int64, workerID string) (*domain.Video, error) {
+	query := `UPDATE videos` + claimSet + `
+		WHERE id = $2 AND ` + due + `
+		RETURNING ` + videoColumns
+	return r.claim(ctx, query, workerID, id)
+}
+
+func (r *postgresVideoRepository) ClaimNextPending(ctx context.Context, workerID string) (*domain.Video, error) {
+	query := `UPDATE videos` + claimSet + `
+		WHERE id = (
+			SELECT id FROM videos
+			WHERE ` + due + `
+			ORDER BY created_at ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + videoColumns
+	return r.claim(ctx, query, workerID)
+}
+
+func (r *postgresVideoRepository) claim(ctx context.Context, query string, args ...any) (*domain.Video, error) {
+	video := &domain.Video{}
+	err := scanVideo(conn(ctx, r.db).QueryRow(ctx, query, args...), video)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return video, nil
+}
+
+func (r *postgresVideoRepository) Heartbeat(ctx context.Context, id int64, workerID string) error {
+	query := `UPDATE videos SET heartbeat_at = NOW() WHERE id = $1 AND status = 'PROCESSING' AND worker_id = $2`
+	tag, err := conn(ctx, r.db).Exec(ctx, query, id, workerID)
+	return owned(tag, err)
+}
+
+// staleCondition is true for PROCESSING rows whose last sign of life is older than $1 seconds
+const staleCondition = `status = 'PROCESSING' AND GREATEST(heartbeat_at, updated_at) < NOW() - make_interval(secs => $1)`
+
+func (r *postgresVideoRepository) GetStale(ctx context.Context, staleAfter time.Duration) ([]domain.Video, error) {
+	query := `SELECT ` + videoColumns + ` FROM videos WHERE ` + staleCondition + ` ORDER BY updated_at ASC`
+	return r.queryVideos(ctx, query, staleAfter.Seconds())
+}
+
+func (r *postgresVideoRepository) ReleaseStale(ctx context.Context, id int64, status, message string, staleAfter time.Duration) (bool, error) {
+	// Re-checking staleness makes this safe against a late heartbeat and against other reapers
+	query := `
+		UPDATE videos
+		SET status = $2, message = $3, heartbeat_at = NULL, updated_at = NOW()
+		WHERE id = $4 AND ` + staleCondition
+	tag, err := conn(ctx, r.db).Exec(ctx, query, staleAfter.Seconds(), status, message, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
 func (r *postgresVideoRepository) GetByID(ctx context.Context, id int64) (*domain.Video, error) {
-	query := `SELECT id, user_id, filename, status, COALESCE(zip_path, ''), frame_count, COALESCE(message, ''), created_at, updated_at FROM videos WHERE id = $1`
+	query := `SELECT ` + videoColumns + ` FROM videos WHERE id = $1`
 	video := &domain.Video{}
-	err := r.db.QueryRow(ctx, query, id).
-		Scan(&video.ID, &video.UserID, &video.Filename, &video.Status, &video.ZipPath, &video.FrameCount, &video.Message, &video.CreatedAt, &video.UpdatedAt)
+	err := scanVideo(conn(ctx, r.db).QueryRow(ctx, query, id), video)
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
 	return video, err
 }
 
-func (r *postgresVideoRepository) GetPending(ctx context.Context) ([]domain.Video, error) {
-	query := `SELECT id, user_id, filename, status, COALESCE(zip_path, ''), frame_count, COALESCE(message, ''), created_at, updated_at FROM videos WHERE status = 'PENDING' ORDER BY created_at ASC`
-	rows, err := r.db.Query(ctx, query)
+func (r *postgresVideoRepository) GetPending(ctx context.Context, afterID int64, limit int) ([]domain.Video, error) {
+	// Keyset pagination, IDs grow with created_at
+	query := `SELECT ` + videoColumns + ` FROM videos WHERE ` + due + ` AND id > $1 ORDER BY id ASC LIMIT $2`
+	return r.queryVideos(ctx, query, afterID, limit)
+}
+
+func (r *postgresVideoRepository) queryVideos(ctx context.Context, query string, args ...any) ([]domain.Video, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -53,11 +166,10 @@ func (r *postgresVideoRepository) GetPending(ctx context.Context) ([]domain.Vide
 	var videos []domain.Video
 	for rows.Next() {
 		var v domain.Video
-		err := rows.Scan(&v.ID, &v.UserID, &v.Filename, &v.Status, &v.ZipPath, &v.FrameCount, &v.Message, &v.CreatedAt, &v.UpdatedAt)
-		if err != nil {
+		if err := scanVideo(rows, &v); err != nil {
 			return nil, err
 		}
 		videos = append(videos, v)
 	}
-	return videos, nil
+	return videos, rows.Err()
 }
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveEncoder writes files into an archive of one format
+type archiveEncoder interface {
+	add(name string, size int64, modified time.Time, r io.Reader) error
+	// finish completes the archive, the underlying writer stays open
+	finish() error
+}
+
+func newArchiveEncoder(w io.Writer, format string) (archiveEncoder, error) {
+	switch format {
+	case domain.ArchiveFormatZip:
+		return &zipEncoder{zw: zip.NewWriter(w)}, nil
+	case domain.ArchiveFormatTar:
+		return &tarEncoder{tw: tar.NewWriter(w)}, nil
+	case domain.ArchiveFormatTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarEncoder{tw: tar.NewWriter(gz), compressor: gz}, nil
+	case domain.ArchiveFormatTarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &tarEncoder{tw: tar.NewWriter(zw), compressor: zw}, nil
+	}
+	return nil, fmt.Errorf("unsupported archive format %q", format)
+}
+
+type zipEncoder struct {
+	zw *zip.Writer
+}
+
+func (e *zipEncoder) add(name string, size int64, modified time.Time, r io.Reader) error {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zipMethod(name),
+		Modified: modified,
+	}
+	writer, err := e.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, r)
+	return err
+}
+
+func (e *zipEncoder) finish() error {
+	return e.zw.Close()
+}
+
+// zipMethod stores already-compressed images as-is, deflating them again only costs CPU
+func zipMethod(filename string) uint16 {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jpg", ".jpeg", ".webp":
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+// tarEncoder writes a tar stream, compressed when compressor is set
+type tarEncoder struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser
+}
+
+func (e *tarEncoder) add(name string, size int64, modified time.Time, r io.Reader) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  modified,
+		Format:   tar.FormatPAX,
+	}
+	if err := e.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(e.tw, r)
+	return err
+}
+
+func (e *tarEncoder) finish() error {
+	if err := e.tw.Close(); err != nil {
+		return err
+	}
+	if e.compressor != nil {
+		return e.compressor.Close()
+	}
+	return nil
+}
+
+// streamArchive adds the files it is given straight to an encoded stream
+type streamArchive struct {
+	enc archiveEncoder
+}
+
+func (a *streamArchive) AddFile(name string, data []byte) error {
+	return a.enc.add(name, int64(len(data)), time.Now(), bytes.NewReader(data))
+}
+
+// checksumWriter counts and hashes the bytes written through it
+type checksumWriter struct {
+	w    io.Writer
+	hash hash.Hash
+	size int64
+}
+
+func newChecksumWriter(w io.Writer) *checksumWriter {
+	return &checksumWriter{w: w, hash: sha256.New()}
+}
+
+func (c *checksumWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.hash.Write(p[:n])
+	c.size += int64(n)
+	return n, err
+}
+
+func (c *checksumWriter) info() domain.ArchiveInfo {
+	return domain.ArchiveInfo{Size: c.size, SHA256: hex.EncodeToString(c.hash.Sum(nil))}
+}
+
+// writeArchive writes an archive holding files, flattened to their base names,
+// followed by the extra entries to w
+func writeArchive(w io.Writer, format string, files []string, extra []ports.ArchiveEntry) error {
+	enc, err := newArchiveEncoder(w, format)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := addFileToArchive(enc, file); err != nil {
+			return err
+		}
+	}
+	for _, entry := range extra {
+		if err := enc.add(entry.Name, int64(len(entry.Data)), time.Now(), bytes.NewReader(entry.Data)); err != nil {
+			return err
+		}
+	}
+	return enc.finish()
+}
+
+func addFileToArchive(enc archiveEncoder, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	return enc.add(filepath.Base(filename), info.Size(), info.ModTime(), file)
+}
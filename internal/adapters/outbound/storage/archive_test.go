@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readArchive returns the files of an archive by name
+func readArchive(t *testing.T, format string, data []byte) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+
+	if format == domain.ArchiveFormatZip {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+		return files
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	switch format {
+	case domain.ArchiveFormatTarGz:
+		gz, err := gzip.NewReader(r)
+		require.NoError(t, err)
+		r = gz
+	case domain.ArchiveFormatTarZst:
+		zr, err := zstd.NewReader(r)
+		require.NoError(t, err)
+		defer zr.Close()
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+}
+
+func TestArchiveFormats(t *testing.T) {
+	dir := t.TempDir()
+	frame1 := filepath.Join(dir, "frame_0001.png")
+	frame2 := filepath.Join(dir, "frame_0002.png")
+	require.NoError(t, os.WriteFile(frame1, []byte("first"), 0644))
+	require.NoError(t, os.WriteFile(frame2, []byte("second"), 0644))
+
+	for _, format := range domain.ArchiveFormats {
+		t.Run(format+" from files", func(t *testing.T) {
+			var buf bytes.Buffer
+			extra := []ports.ArchiveEntry{{Name: "manifest.json", Data: []byte("{}")}}
+			require.NoError(t, writeArchive(&buf, format, []string{frame1, frame2}, extra))
+
+			assert.Equal(t, map[string]string{"frame_0001.png": "first", "frame_0002.png": "second", "manifest.json": "{}"}, readArchive(t, format, buf.Bytes()))
+		})
+
+		t.Run(format+" streamed", func(t *testing.T) {
+			var buf bytes.Buffer
+			enc, err := newArchiveEncoder(&buf, format)
+			require.NoError(t, err)
+			archive := &streamArchive{enc: enc}
+			require.NoError(t, archive.AddFile("frame_0001.jpg", []byte("jpeg")))
+			require.NoError(t, enc.finish())
+
+			assert.Equal(t, map[string]string{"frame_0001.jpg": "jpeg"}, readArchive(t, format, buf.Bytes()))
+		})
+	}
+
+	t.Run("unknown format is rejected", func(t *testing.T) {
+		_, err := newArchiveEncoder(io.Discard, "rar")
+		assert.Error(t, err)
+	})
+}
@@ -1,7 +1,7 @@
 package storage
 
 import (
-	"archive/zip"
+	"context"
 	"io"
 	"os"
 	"path/filepath"
@@ -44,52 +44,103 @@ func (s *fsStorage) SaveUpload(filename string, data io.Reader) (string, error)
 	return path, err
 }
 
-func (s *fsStorage) SaveZip(zipFilename string, files []string) error {
-	zipPath := filepath.Join(s.outputDir, zipFilename)
-	zipFile, err := os.Create(zipPath)
+// SaveArchive writes the archive under a temporary name and renames it into
+// place once it is on disk, so a crash never leaves a truncated archive behind
+func (s *fsStorage) SaveArchive(name, format string, files []string, extra ...ports.ArchiveEntry) (domain.ArchiveInfo, error) {
+	file, err := s.createTemp(name)
 	if err != nil {
-		return err
+		return domain.ArchiveInfo{}, err
 	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
 
-	for _, file := range files {
-		if err := s.addFileToZip(zipWriter, file); err != nil {
-			return err
-		}
+	sum := newChecksumWriter(file)
+	if err := writeArchive(sum, format, files, extra); err != nil {
+		discardFile(file)
+		return domain.ArchiveInfo{}, err
 	}
-	return nil
+	if err := commitFile(file, filepath.Join(s.outputDir, name)); err != nil {
+		return domain.ArchiveInfo{}, err
+	}
+	return sum.info(), nil
 }
 
-func (s *fsStorage) addFileToZip(zipWriter *zip.Writer, filename string) error {
-	file, err := os.Open(filename)
+func (s *fsStorage) CreateArchive(name, format string) (ports.ArchiveWriter, error) {
+	file, err := s.createTemp(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer file.Close()
-
-	info, err := file.Stat()
+	sum := newChecksumWriter(file)
+	enc, err := newArchiveEncoder(sum, format)
 	if err != nil {
-		return err
+		discardFile(file)
+		return nil, err
 	}
+	return &fsArchive{
+		streamArchive: &streamArchive{enc: enc},
+		file:          file,
+		sum:           sum,
+		path:          filepath.Join(s.outputDir, name),
+	}, nil
+}
+
+// createTemp creates a hidden file next to the archive so the final rename
+// stays on one filesystem. Its .tmp suffix keeps it out of ListOutputs.
+func (s *fsStorage) createTemp(name string) (*os.File, error) {
+	return os.CreateTemp(s.outputDir, "."+name+".*.tmp")
+}
 
-	header, err := zip.FileInfoHeader(info)
+// commitFile flushes file to disk, closes it and renames it to path
+func commitFile(file *os.File, path string) error {
+	if err := file.Sync(); err != nil {
+		discardFile(file)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	// The rename is only durable once the directory itself is synced
+	dir, err := os.Open(filepath.Dir(path))
 	if err != nil {
 		return err
 	}
+	defer dir.Close()
+	return dir.Sync()
+}
 
-	header.Name = filepath.Base(filename)
-	header.Method = zip.Deflate
+func discardFile(file *os.File) error {
+	file.Close()
+	return os.Remove(file.Name())
+}
 
-	writer, err := zipWriter.CreateHeader(header)
-	if err != nil {
-		return err
+type fsArchive struct {
+	*streamArchive
+	file *os.File
+	sum  *checksumWriter
+	path string
+}
+
+func (a *fsArchive) Close() (domain.ArchiveInfo, error) {
+	if err := a.enc.finish(); err != nil {
+		a.Abort()
+		return domain.ArchiveInfo{}, err
+	}
+	if err := commitFile(a.file, a.path); err != nil {
+		return domain.ArchiveInfo{}, err
 	}
+	return a.sum.info(), nil
+}
 
-	_, err = io.Copy(writer, file)
-	return err
+func (a *fsArchive) Abort() error {
+	return discardFile(a.file)
+}
+
+// FetchUpload returns the upload in place, it is already on local disk
+func (s *fsStorage) FetchUpload(ctx context.Context, filename string) (string, func(), error) {
+	return s.GetUploadPath(filename), func() {}, nil
 }
 
 func (s *fsStorage) DeleteFile(path string) error {
@@ -101,15 +152,19 @@ func (s *fsStorage) DeleteDir(path string) error {
 }
 
 func (s *fsStorage) ListOutputs() ([]domain.FileInfo, error) {
-	files, err := filepath.Glob(filepath.Join(s.outputDir, "*.zip"))
+	files, err := filepath.Glob(filepath.Join(s.outputDir, "*"))
 	if err != nil {
 		return nil, err
 	}
 
 	var results []domain.FileInfo
 	for _, file := range files {
+		format := domain.ArchiveFormatOf(file)
+		if format == "" {
+			continue
+		}
 		info, err := os.Stat(file)
-		if err != nil {
+		if err != nil || !info.Mode().IsRegular() {
 			continue
 		}
 
@@ -118,6 +173,7 @@ func (s *fsStorage) ListOutputs() ([]domain.FileInfo, error) {
 			Size:        info.Size(),
 			CreatedAt:   info.ModTime().Format("2006-01-02 15:04:05"),
 			DownloadURL: "/download/" + filepath.Base(file),
+			ContentType: domain.ArchiveContentType(format),
 		})
 	}
 	return results, nil
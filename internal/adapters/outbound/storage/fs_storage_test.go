@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFSStorage(t *testing.T) *fsStorage {
+	dir := t.TempDir()
+	s := &fsStorage{
+		uploadDir: filepath.Join(dir, "uploads"),
+		outputDir: filepath.Join(dir, "outputs"),
+		tempDir:   filepath.Join(dir, "temp"),
+	}
+	s.createDirs()
+	return s
+}
+
+// assertChecksum checks info against the archive actually on disk
+func assertChecksum(t *testing.T, path string, info domain.ArchiveInfo) {
+	data := mustRead(t, path)
+	sum := sha256.Sum256(data)
+	assert.Equal(t, hex.EncodeToString(sum[:]), info.SHA256)
+	assert.Equal(t, int64(len(data)), info.Size)
+}
+
+// outputNames lists every entry of the output dir, hidden ones included
+func outputNames(t *testing.T, s *fsStorage) []string {
+	entries, err := os.ReadDir(s.outputDir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestFSStorage_SaveArchive(t *testing.T) {
+	frame := filepath.Join(t.TempDir(), "frame_0001.png")
+	require.NoError(t, os.WriteFile(frame, []byte("png"), 0644))
+
+	t.Run("renames the complete archive into place", func(t *testing.T) {
+		s := newTestFSStorage(t)
+
+		info, err := s.SaveArchive("frames_video.tar.gz", domain.ArchiveFormatTarGz, []string{frame},
+			ports.ArchiveEntry{Name: domain.ManifestFilename, Data: []byte("{}")})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"frames_video.tar.gz"}, outputNames(t, s))
+		assertChecksum(t, s.GetOutputPath("frames_video.tar.gz"), info)
+		assert.Equal(t, map[string]string{"frame_0001.png": "png", "manifest.json": "{}"},
+			readArchive(t, domain.ArchiveFormatTarGz, mustRead(t, s.GetOutputPath("frames_video.tar.gz"))))
+	})
+
+	t.Run("failed write leaves nothing behind", func(t *testing.T) {
+		s := newTestFSStorage(t)
+
+		_, err := s.SaveArchive("frames_video.zip", domain.ArchiveFormatZip, []string{frame, "/missing/frame_0002.png"})
+
+		assert.Error(t, err)
+		assert.Empty(t, outputNames(t, s))
+	})
+
+	t.Run("failed write keeps the previous archive", func(t *testing.T) {
+		s := newTestFSStorage(t)
+		first, err := s.SaveArchive("frames_video.zip", domain.ArchiveFormatZip, []string{frame})
+		require.NoError(t, err)
+
+		_, err = s.SaveArchive("frames_video.zip", domain.ArchiveFormatZip, []string{frame, "/missing/frame_0002.png"})
+
+		assert.Error(t, err)
+		assert.Equal(t, []string{"frames_video.zip"}, outputNames(t, s))
+		assertChecksum(t, s.GetOutputPath("frames_video.zip"), first)
+	})
+}
+
+func TestFSStorage_CreateArchive(t *testing.T) {
+	t.Run("archive is hidden until closed", func(t *testing.T) {
+		s := newTestFSStorage(t)
+		archive, err := s.CreateArchive("frames_video.zip", domain.ArchiveFormatZip)
+		require.NoError(t, err)
+		require.NoError(t, archive.AddFile("frame_0001.png", []byte("png")))
+
+		outputs, err := s.ListOutputs()
+		require.NoError(t, err)
+		assert.Empty(t, outputs)
+		assert.NoFileExists(t, s.GetOutputPath("frames_video.zip"))
+
+		info, err := archive.Close()
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"frames_video.zip"}, outputNames(t, s))
+		assertChecksum(t, s.GetOutputPath("frames_video.zip"), info)
+	})
+
+	t.Run("abort removes the partial archive", func(t *testing.T) {
+		s := newTestFSStorage(t)
+		archive, err := s.CreateArchive("frames_video.zip", domain.ArchiveFormatZip)
+		require.NoError(t, err)
+		require.NoError(t, archive.AddFile("frame_0001.png", []byte("png")))
+
+		require.NoError(t, archive.Abort())
+
+		assert.Empty(t, outputNames(t, s))
+	})
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// partSize is the multipart upload part size. Archives are streamed without
+// a known length, so it also bounds the memory buffered per upload.
+const partSize = 16 << 20
+
+// S3Config describes the bucket holding uploads and outputs
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	Bucket    string
+
+	// UploadPrefix and OutputPrefix are the key prefixes of uploads and ZIPs
+	UploadPrefix string
+	OutputPrefix string
+
+	// ScratchDir holds the local copies of uploads while they are processed
+	ScratchDir string
+}
+
+func DefaultS3Config() S3Config {
+	return S3Config{
+		Endpoint:     "minio:9000",
+		Region:       "us-east-1",
+		Bucket:       "videos",
+		UploadPrefix: "uploads/",
+		OutputPrefix: "outputs/",
+		ScratchDir:   "/app/temp/uploads",
+	}
+}
+
+type s3Storage struct {
+	client *minio.Client
+	cfg    S3Config
+}
+
+// NewS3Storage stores uploads and ZIPs in an S3-compatible bucket, creating it if needed
+func NewS3Storage(ctx context.Context, cfg S3Config) (ports.Storage, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating S3 client: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("error checking bucket %s: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region})
+		if err != nil && minio.ToErrorResponse(err).Code != "BucketAlreadyOwnedByYou" {
+			return nil, fmt.Errorf("error creating bucket %s: %w", cfg.Bucket, err)
+		}
+	}
+
+	if err := os.MkdirAll(cfg.ScratchDir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating scratch dir: %w", err)
+	}
+
+	return &s3Storage{client: client, cfg: cfg}, nil
+}
+
+func (s *s3Storage) SaveUpload(filename string, data io.Reader) (string, error) {
+	_, err := s.client.PutObject(context.Background(), s.cfg.Bucket, s.cfg.UploadPrefix+filename, data, -1, minio.PutObjectOptions{
+		PartSize: partSize,
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.GetUploadPath(filename), nil
+}
+
+// SaveArchive streams the archive into a multipart upload, it never touches
+// the local disk. The object only appears once the upload completes.
+func (s *s3Storage) SaveArchive(name, format string, files []string, extra ...ports.ArchiveEntry) (domain.ArchiveInfo, error) {
+	pr, pw := io.Pipe()
+	sum := newChecksumWriter(pw)
+	go func() {
+		pw.CloseWithError(writeArchive(sum, format, files, extra))
+	}()
+
+	_, err := s.client.PutObject(context.Background(), s.cfg.Bucket, s.cfg.OutputPrefix+name, pr, -1, minio.PutObjectOptions{
+		ContentType: domain.ArchiveContentType(format),
+		PartSize:    partSize,
+	})
+	// Unblocks the writer if the upload gave up first
+	pr.CloseWithError(err)
+	if err != nil {
+		return domain.ArchiveInfo{}, err
+	}
+	// The upload read up to EOF, so the writer is done with sum
+	return sum.info(), nil
+}
+
+// CreateArchive streams the archive into a multipart upload as it is written.
+// An aborted or failed upload leaves no object behind.
+func (s *s3Storage) CreateArchive(name, format string) (ports.ArchiveWriter, error) {
+	pr, pw := io.Pipe()
+	sum := newChecksumWriter(pw)
+	enc, err := newArchiveEncoder(sum, format)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.client.PutObject(context.Background(), s.cfg.Bucket, s.cfg.OutputPrefix+name, pr, -1, minio.PutObjectOptions{
+			ContentType: domain.ArchiveContentType(format),
+			PartSize:    partSize,
+		})
+		// Fails the pending writes if the upload gave up first
+		pr.CloseWithError(err)
+		done <- err
+	}()
+	return &s3Archive{streamArchive: &streamArchive{enc: enc}, pw: pw, sum: sum, done: done}, nil
+}
+
+var errArchiveAborted = errors.New("archive aborted")
+
+type s3Archive struct {
+	*streamArchive
+	pw   *io.PipeWriter
+	sum  *checksumWriter
+	done chan error
+}
+
+func (a *s3Archive) Close() (domain.ArchiveInfo, error) {
+	if err := a.enc.finish(); err != nil {
+		a.pw.CloseWithError(err)
+		<-a.done
+		return domain.ArchiveInfo{}, err
+	}
+	a.pw.Close()
+	if err := <-a.done; err != nil {
+		return domain.ArchiveInfo{}, err
+	}
+	return a.sum.info(), nil
+}
+
+func (a *s3Archive) Abort() error {
+	a.pw.CloseWithError(errArchiveAborted)
+	<-a.done
+	return nil
+}
+
+// FetchUpload downloads the upload to its own scratch dir, so concurrent
+// attempts of the same video do not share a file
+func (s *s3Storage) FetchUpload(ctx context.Context, filename string) (string, func(), error) {
+	dir, err := os.MkdirTemp(s.cfg.ScratchDir, "upload-*")
+	if err != nil {
+		return "", nil, err
+	}
+	release := func() { os.RemoveAll(dir) }
+
+	localPath := filepath.Join(dir, filepath.Base(filename))
+	err = s.client.FGetObject(ctx, s.cfg.Bucket, s.cfg.UploadPrefix+filename, localPath, minio.GetObjectOptions{})
+	if err != nil {
+		release()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return "", nil, fmt.Errorf("%w: upload %s not found", domain.ErrInvalidVideo, filename)
+		}
+		return "", nil, fmt.Errorf("error downloading upload %s: %w", filename, err)
+	}
+	return localPath, release, nil
+}
+
+// DeleteFile removes an object when given a path from GetUploadPath or
+// GetOutputPath, and a local file otherwise
+func (s *s3Storage) DeleteFile(p string) error {
+	if key, ok := s.objectKey(p); ok {
+		return s.client.RemoveObject(context.Background(), s.cfg.Bucket, key, minio.RemoveObjectOptions{})
+	}
+	return os.Remove(p)
+}
+
+func (s *s3Storage) DeleteDir(p string) error {
+	return os.RemoveAll(p)
+}
+
+// ListOutputs pages over the output prefix; the client follows the continuation tokens
+func (s *s3Storage) ListOutputs() ([]domain.FileInfo, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var results []domain.FileInfo
+	for object := range s.client.ListObjects(ctx, s.cfg.Bucket, minio.ListObjectsOptions{
+		Prefix:    s.cfg.OutputPrefix,
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+
+		name := strings.TrimPrefix(object.Key, s.cfg.OutputPrefix)
+		format := domain.ArchiveFormatOf(name)
+		if format == "" || strings.Contains(name, "/") {
+			continue
+		}
+
+		results = append(results, domain.FileInfo{
+			Name:        name,
+			Size:        object.Size,
+			CreatedAt:   object.LastModified.Format("2006-01-02 15:04:05"),
+			DownloadURL: "/download/" + name,
+			ContentType: domain.ArchiveContentType(format),
+		})
+	}
+	return results, nil
+}
+
+func (s *s3Storage) GetOutputPath(filename string) string {
+	return s.objectURL(s.cfg.OutputPrefix + filename)
+}
+
+func (s *s3Storage) GetUploadPath(filename string) string {
+	return s.objectURL(s.cfg.UploadPrefix + filename)
+}
+
+func (s *s3Storage) objectURL(key string) string {
+	return "s3://" + s.cfg.Bucket + "/" + key
+}
+
+func (s *s3Storage) objectKey(p string) (string, bool) {
+	return strings.CutPrefix(p, "s3://"+s.cfg.Bucket+"/")
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"video-processor-worker/internal/core/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Runs against a local S3-compatible server and is skipped unless it is configured:
+//
+//	S3_ENDPOINT=localhost:9000 S3_ACCESS_KEY=minioadmin S3_SECRET_KEY=minioadmin go test ./internal/adapters/outbound/storage/
+func TestS3Storage(t *testing.T) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_ENDPOINT not set")
+	}
+
+	cfg := DefaultS3Config()
+	cfg.Endpoint = endpoint
+	cfg.AccessKey = os.Getenv("S3_ACCESS_KEY")
+	cfg.SecretKey = os.Getenv("S3_SECRET_KEY")
+	cfg.Bucket = fmt.Sprintf("test-%d", time.Now().UnixNano())
+	cfg.ScratchDir = t.TempDir()
+
+	ctx := context.Background()
+	storage, err := NewS3Storage(ctx, cfg)
+	require.NoError(t, err)
+
+	uploadPath, err := storage.SaveUpload("video.mp4", strings.NewReader("not really a video"))
+	require.NoError(t, err)
+	assert.Equal(t, storage.GetUploadPath("video.mp4"), uploadPath)
+
+	t.Run("fetches the upload to a scratch copy", func(t *testing.T) {
+		path, release, err := storage.FetchUpload(ctx, "video.mp4")
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "not really a video", string(data))
+
+		release()
+		assert.NoFileExists(t, path)
+	})
+
+	t.Run("missing upload is an invalid video", func(t *testing.T) {
+		_, _, err := storage.FetchUpload(ctx, "missing.mp4")
+		assert.ErrorIs(t, err, domain.ErrInvalidVideo)
+	})
+
+	t.Run("saves and lists ZIPs", func(t *testing.T) {
+		dir := t.TempDir()
+		frame := filepath.Join(dir, "frame_0001.jpg")
+		require.NoError(t, os.WriteFile(frame, []byte("jpeg"), 0644))
+
+		info, err := storage.SaveArchive("frames_video.zip", domain.ArchiveFormatZip, []string{frame})
+		require.NoError(t, err)
+		assert.Len(t, info.SHA256, 64)
+
+		outputs, err := storage.ListOutputs()
+		require.NoError(t, err)
+		require.Len(t, outputs, 1)
+		assert.Equal(t, "frames_video.zip", outputs[0].Name)
+		assert.Equal(t, "/download/frames_video.zip", outputs[0].DownloadURL)
+		assert.Equal(t, "application/zip", outputs[0].ContentType)
+		assert.Equal(t, info.Size, outputs[0].Size)
+
+		require.NoError(t, storage.DeleteFile(storage.GetOutputPath("frames_video.zip")))
+	})
+
+	t.Run("deletes the upload", func(t *testing.T) {
+		require.NoError(t, storage.DeleteFile(uploadPath))
+
+		_, _, err := storage.FetchUpload(ctx, "video.mp4")
+		assert.ErrorIs(t, err, domain.ErrInvalidVideo)
+	})
+}
@@ -0,0 +1,66 @@
+package domain
+
+import "strings"
+
+const (
+	ArchiveFormatZip    = "zip"
+	ArchiveFormatTar    = "tar"
+	ArchiveFormatTarGz  = "tar.gz"
+	ArchiveFormatTarZst = "tar.zst"
+
+	DefaultArchiveFormat = ArchiveFormatZip
+)
+
+// ArchiveInfo describes a stored output archive
+type ArchiveInfo struct {
+	Size   int64  // bytes
+	SHA256 string // hex digest of the whole archive
+}
+
+// ArchiveFormats lists every supported archive format
+var ArchiveFormats = []string{ArchiveFormatZip, ArchiveFormatTar, ArchiveFormatTarGz, ArchiveFormatTarZst}
+
+// ParseArchiveFormat returns the canonical name of an archive format, or ""
+// when it is not supported
+func ParseArchiveFormat(format string) string {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case ArchiveFormatZip:
+		return ArchiveFormatZip
+	case ArchiveFormatTar:
+		return ArchiveFormatTar
+	case ArchiveFormatTarGz, "tgz", "gzip":
+		return ArchiveFormatTarGz
+	case ArchiveFormatTarZst, "tar.zstd", "tzst", "zstd":
+		return ArchiveFormatTarZst
+	}
+	return ""
+}
+
+// ArchiveExtension returns the file extension, with the dot, of an archive format
+func ArchiveExtension(format string) string {
+	return "." + format
+}
+
+// ArchiveContentType returns the MIME type an archive format is served with
+func ArchiveContentType(format string) string {
+	switch format {
+	case ArchiveFormatTar:
+		return "application/x-tar"
+	case ArchiveFormatTarGz:
+		return "application/gzip"
+	case ArchiveFormatTarZst:
+		return "application/zstd"
+	default:
+		return "application/zip"
+	}
+}
+
+// ArchiveFormatOf returns the format of an archive file from its name, or ""
+func ArchiveFormatOf(filename string) string {
+	for _, format := range ArchiveFormats {
+		if strings.HasSuffix(filename, ArchiveExtension(format)) {
+			return format
+		}
+	}
+	return ""
+}
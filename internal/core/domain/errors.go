@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidVideo means the upload is empty, corrupt or in a format we cannot process
+	ErrInvalidVideo = errors.New("invalid or unsupported video")
+
+	// ErrProcessingTimeout means extraction did not finish within the per-job deadline
+	ErrProcessingTimeout = errors.New("processing timed out")
+
+	// ErrLostOwnership means the video was released or claimed by another worker while this one processed it
+	ErrLostOwnership = errors.New("video is no longer owned by this worker")
+)
+
+// RetryError means the video went back to PENDING and should be attempted
+// again after Delay. Inbound adapters use it to schedule redelivery.
+type RetryError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("retry in %s: %v", e.Delay, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether retrying err cannot succeed
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrInvalidVideo) || errors.Is(err, ErrProcessingTimeout)
+}
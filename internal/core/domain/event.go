@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventSchemaVersion is bumped on every breaking change to VideoEvent
+const EventSchemaVersion = 1
+
+const (
+	EventProcessingStarted   = "video.processing.started"
+	EventProcessingProgress  = "video.processing.progress"
+	EventProcessingCompleted = "video.processing.completed"
+	EventProcessingFailed    = "video.processing.failed"
+)
+
+// VideoEvent tells other services about a change in the processing of a video
+type VideoEvent struct {
+	Version    int       `json:"version"`
+	Type       string    `json:"type"`
+	VideoID    int64     `json:"video_id"`
+	UserID     int64     `json:"user_id"`
+	Status     string    `json:"status"`
+	Attempt    int       `json:"attempt"`
+	ZipPath    string    `json:"zip_path,omitempty"`
+	Archive    string    `json:"archive_format,omitempty"`
+	Checksum   string    `json:"archive_sha256,omitempty"`
+	FrameCount int       `json:"frame_count,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Progress   *Progress `json:"progress,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func NewVideoEvent(eventType string, video *Video) VideoEvent {
+	event := VideoEvent{
+		Version:    EventSchemaVersion,
+		Type:       eventType,
+		VideoID:    video.ID,
+		UserID:     video.UserID,
+		Status:     video.Status,
+		Attempt:    video.Attempts,
+		ZipPath:    video.ZipPath,
+		Archive:    video.ArchiveFormat,
+		Checksum:   video.ArchiveSHA256,
+		FrameCount: video.FrameCount,
+		OccurredAt: time.Now().UTC(),
+	}
+	switch eventType {
+	case EventProcessingFailed:
+		event.Error = video.Message
+	case EventProcessingProgress:
+		progress := video.Progress
+		event.Progress = &progress
+	}
+	return event
+}
+
+// ID identifies the state the event reports, so publishing it twice is
+// deduplicated. Retries start a new attempt and progress moves forward, so
+// both are part of it.
+func (e VideoEvent) ID() string {
+	id := fmt.Sprintf("video-%d.%s.%d", e.VideoID, e.Type, e.Attempt)
+	if e.Progress != nil {
+		id += fmt.Sprintf(".%d", e.Progress.Frames)
+	}
+	return id
+}
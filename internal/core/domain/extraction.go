@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	ExtractionModeFixed = "fixed" // fixed-rate sampling (fps or interval)
+	ExtractionModeScene = "scene" // one frame per detected scene change
+
+	DefaultFPS            = 1.0
+	MaxFPS                = 60.0
+	DefaultSceneThreshold = 0.3
+
+	ImageFormatPNG  = "png"
+	ImageFormatJPEG = "jpeg"
+	ImageFormatWebP = "webp"
+
+	DefaultImageQuality = 85
+)
+
+// ExtractionOptions controls how frames are sampled from a video.
+// Zero values mean "use the default" so an empty options object keeps the
+// historical behaviour of one frame per second over the whole file.
+type ExtractionOptions struct {
+	Mode      string  `json:"mode,omitempty"`       // ExtractionModeFixed or ExtractionModeScene
+	FPS       float64 `json:"fps,omitempty"`        // frames per second
+	Interval  float64 `json:"interval,omitempty"`   // one frame every N seconds, takes precedence over FPS
+	StartTime float64 `json:"start_time,omitempty"` // seconds from the beginning of the video
+	EndTime   float64 `json:"end_time,omitempty"`   // seconds from the beginning of the video, 0 means until the end
+	MaxFrames int     `json:"max_frames,omitempty"` // 0 means no limit
+
+	// Scene mode only
+	SceneThreshold float64 `json:"scene_threshold,omitempty"` // ffmpeg scene score in (0, 1), higher means fewer frames
+	MinFrames      int     `json:"min_frames,omitempty"`      // fall back to fixed-rate sampling below this count
+
+	// Output images
+	Format    string `json:"format,omitempty"`     // ImageFormatPNG, ImageFormatJPEG or ImageFormatWebP
+	Quality   int    `json:"quality,omitempty"`    // 1-100, lossy formats only
+	MaxWidth  int    `json:"max_width,omitempty"`  // 0 means original width
+	MaxHeight int    `json:"max_height,omitempty"` // 0 means original height
+
+	// Output archive, one of ArchiveFormats. Empty means the worker default.
+	ArchiveFormat string `json:"archive_format,omitempty"`
+}
+
+// ExtractionResult is what a VideoProcessor produced for a video
+type ExtractionResult struct {
+	Frames     []string    // frame files, empty when the frames were streamed
+	FrameCount int         // frames handed to the sink when streaming
+	FrameInfo  []FrameInfo // one entry per frame, in order
+	Mode       string      // the mode that actually produced the frames
+}
+
+func DefaultExtractionOptions() ExtractionOptions {
+	return ExtractionOptions{Mode: ExtractionModeFixed, FPS: DefaultFPS, Format: ImageFormatPNG}
+}
+
+// Normalize returns a copy of the options with missing or invalid values
+// replaced by the defaults.
+func (o ExtractionOptions) Normalize() ExtractionOptions {
+	n := o
+
+	if n.Mode != ExtractionModeScene {
+		n.Mode = ExtractionModeFixed
+	}
+
+	if !validNumber(n.Interval) || n.Interval < 0 {
+		n.Interval = 0
+	}
+	if n.Interval > 0 {
+		n.FPS = 0
+	} else if !validNumber(n.FPS) || n.FPS <= 0 || n.FPS > MaxFPS {
+		n.FPS = DefaultFPS
+	}
+
+	if !validNumber(n.StartTime) || !validNumber(n.EndTime) || n.StartTime < 0 || n.EndTime < 0 ||
+		(n.EndTime > 0 && n.EndTime <= n.StartTime) {
+		n.StartTime = 0
+		n.EndTime = 0
+	}
+
+	if n.MaxFrames < 0 {
+		n.MaxFrames = 0
+	}
+
+	if n.Mode == ExtractionModeScene {
+		if !validNumber(n.SceneThreshold) || n.SceneThreshold <= 0 || n.SceneThreshold >= 1 {
+			n.SceneThreshold = DefaultSceneThreshold
+		}
+		if n.MinFrames < 0 {
+			n.MinFrames = 0
+		}
+		if n.MaxFrames > 0 && n.MinFrames > n.MaxFrames {
+			n.MinFrames = n.MaxFrames
+		}
+	} else {
+		n.SceneThreshold = 0
+		n.MinFrames = 0
+	}
+
+	switch strings.ToLower(n.Format) {
+	case ImageFormatJPEG, "jpg":
+		n.Format = ImageFormatJPEG
+	case ImageFormatWebP:
+		n.Format = ImageFormatWebP
+	default:
+		n.Format = ImageFormatPNG
+	}
+	if n.Format == ImageFormatPNG {
+		n.Quality = 0
+	} else if n.Quality < 1 || n.Quality > 100 {
+		n.Quality = DefaultImageQuality
+	}
+
+	if n.MaxWidth < 0 {
+		n.MaxWidth = 0
+	}
+	if n.MaxHeight < 0 {
+		n.MaxHeight = 0
+	}
+
+	n.ArchiveFormat = ParseArchiveFormat(n.ArchiveFormat)
+	return n
+}
+
+// Extension returns the file extension, without the dot, of the output images.
+func (o ExtractionOptions) Extension() string {
+	switch o.Format {
+	case ImageFormatJPEG:
+		return "jpg"
+	case ImageFormatWebP:
+		return "webp"
+	default:
+		return "png"
+	}
+}
+
+func validNumber(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
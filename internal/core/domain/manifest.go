@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// ManifestVersion is bumped on every breaking change to FrameManifest
+const ManifestVersion = 1
+
+const (
+	ManifestFilename    = "manifest.json"
+	ManifestCSVFilename = "manifest.csv"
+)
+
+// FrameInfo describes one extracted frame
+type FrameInfo struct {
+	Index     int     `json:"index"` // 1-based, the number in the file name
+	Filename  string  `json:"filename"`
+	Timestamp float64 `json:"timestamp"` // presentation time in the source video, seconds
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Size      int64   `json:"size"` // bytes
+	SHA256    string  `json:"sha256"`
+}
+
+// FrameManifest is stored in every output archive so consumers can map the
+// frames back to the source video
+type FrameManifest struct {
+	Version    int                `json:"version"`
+	VideoID    int64              `json:"video_id"`
+	Source     ManifestSource     `json:"source"`
+	Extraction ManifestExtraction `json:"extraction"`
+	FrameCount int                `json:"frame_count"`
+	CreatedAt  time.Time          `json:"created_at"`
+	Frames     []FrameInfo        `json:"frames"`
+}
+
+type ManifestSource struct {
+	Filename string         `json:"filename"`
+	Metadata *VideoMetadata `json:"metadata,omitempty"`
+}
+
+type ManifestExtraction struct {
+	Options       ExtractionOptions `json:"options"`
+	Mode          string            `json:"mode"` // the mode that actually produced the frames
+	ArchiveFormat string            `json:"archive_format"`
+}
+
+func NewFrameManifest(video *Video, result ExtractionResult, archiveFormat string) FrameManifest {
+	frames := result.FrameInfo
+	if frames == nil {
+		frames = []FrameInfo{}
+	}
+	return FrameManifest{
+		Version: ManifestVersion,
+		VideoID: video.ID,
+		Source: ManifestSource{
+			Filename: video.Filename,
+			Metadata: video.Metadata,
+		},
+		Extraction: ManifestExtraction{
+			Options:       video.Options.Normalize(),
+			Mode:          result.Mode,
+			ArchiveFormat: archiveFormat,
+		},
+		FrameCount: len(frames),
+		CreatedAt:  time.Now().UTC(),
+		Frames:     frames,
+	}
+}
+
+func (m FrameManifest) JSON() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// CSV lists the frames only, one row per frame after a header row
+func (m FrameManifest) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"index", "filename", "timestamp", "width", "height", "size", "sha256"})
+	for _, f := range m.Frames {
+		w.Write([]string{
+			strconv.Itoa(f.Index),
+			f.Filename,
+			strconv.FormatFloat(f.Timestamp, 'f', 6, 64),
+			strconv.Itoa(f.Width),
+			strconv.Itoa(f.Height),
+			strconv.FormatInt(f.Size, 10),
+			f.SHA256,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,39 @@
+package domain
+
+import "fmt"
+
+// VideoMetadata is what the probe found in an uploaded file
+type VideoMetadata struct {
+	Container    string        `json:"container"`
+	Duration     float64       `json:"duration"` // seconds
+	Size         int64         `json:"size"`     // bytes
+	Codec        string        `json:"codec"`
+	Width        int           `json:"width"`
+	Height       int           `json:"height"`
+	FrameRate    float64       `json:"frame_rate"`
+	Rotation     int           `json:"rotation"` // degrees clockwise, 0-359
+	AudioStreams []AudioStream `json:"audio_streams,omitempty"`
+}
+
+type AudioStream struct {
+	Codec      string `json:"codec"`
+	Channels   int    `json:"channels"`
+	SampleRate int    `json:"sample_rate"`
+}
+
+// Validate rejects files we know ffmpeg will not be able to extract frames from
+func (m *VideoMetadata) Validate() error {
+	if m.Size == 0 {
+		return fmt.Errorf("%w: empty file", ErrInvalidVideo)
+	}
+	if m.Codec == "" {
+		return fmt.Errorf("%w: no video stream", ErrInvalidVideo)
+	}
+	if m.Width <= 0 || m.Height <= 0 {
+		return fmt.Errorf("%w: unknown resolution", ErrInvalidVideo)
+	}
+	if m.Duration <= 0 {
+		return fmt.Errorf("%w: zero duration", ErrInvalidVideo)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// OutboxEntry is an event waiting in the outbox to be published
+type OutboxEntry struct {
+	ID        int64      `json:"id"`
+	Event     VideoEvent `json:"event"`
+	Attempts  int        `json:"attempts"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,8 @@
+package domain
+
+// Progress is the state of an in-flight extraction
+type Progress struct {
+	Percent    float64 `json:"percent"`
+	Frames     int     `json:"frames"`
+	ETASeconds float64 `json:"eta_seconds"`
+}
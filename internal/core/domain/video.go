@@ -10,15 +10,34 @@ const (
 )
 
 type Video struct {
-	ID         int64     `json:"id"`
-	UserID     int64     `json:"user_id"`
-	Filename   string    `json:"filename"`
-	Status     string    `json:"status"`
-	ZipPath    string    `json:"zip_path,omitempty"`
-	FrameCount int       `json:"frame_count"`
-	Message    string    `json:"message,omitempty"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID             int64             `json:"id"`
+	UserID         int64             `json:"user_id"`
+	Filename       string            `json:"filename"`
+	Status         string            `json:"status"`
+	ZipPath        string            `json:"zip_path,omitempty"`
+	FrameCount     int               `json:"frame_count"`
+	Message        string            `json:"message,omitempty"`
+	Options        ExtractionOptions `json:"options"`
+	ExtractionMode string            `json:"extraction_mode,omitempty"`   // mode that produced the ZIP
+	ArchiveFormat  string            `json:"archive_format,omitempty"`    // format of the file at ZipPath, not always a ZIP
+	ArchiveExt     string            `json:"archive_extension,omitempty"` // extension of the file at ZipPath, with the dot
+	ArchiveSize    int64             `json:"archive_size,omitempty"`      // bytes of the file at ZipPath
+	ArchiveSHA256  string            `json:"archive_sha256,omitempty"`    // hex SHA-256 of the file at ZipPath
+	Metadata       *VideoMetadata    `json:"metadata,omitempty"`
+	Progress       Progress          `json:"progress"`
+	Attempts       int               `json:"attempts"`
+	HeartbeatAt    *time.Time        `json:"heartbeat_at,omitempty"`
+	WorkerID       string            `json:"worker_id,omitempty"`
+	ClaimedAt      *time.Time        `json:"claimed_at,omitempty"`
+	LastError      string            `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time        `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// ArchiveContentType returns the MIME type the output archive is served with
+func (v *Video) ArchiveContentType() string {
+	return ArchiveContentType(v.ArchiveFormat)
 }
 
 type ProcessingResult struct {
@@ -36,5 +55,6 @@ type FileInfo struct {
 	Size        int64  `json:"size"`
 	CreatedAt   string `json:"created_at"`
 	DownloadURL string `json:"download_url"`
+	ContentType string `json:"content_type,omitempty"`
 	Status      string `json:"status,omitempty"`
 }
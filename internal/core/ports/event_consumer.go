@@ -1,7 +1,16 @@
 package ports
 
-import "context"
+import (
+	"context"
+	"video-processor-worker/internal/core/domain"
+)
+
+// EventHandler processes a video, optionally overriding the extraction options stored on its row
+type EventHandler func(ctx context.Context, videoID int64, opts *domain.ExtractionOptions) error
 
 type EventConsumer interface {
+	// Listen hands events to the worker until ctx is done
 	Listen(ctx context.Context) error
+	// Close releases the connection once in-flight jobs have been acknowledged
+	Close() error
 }
@@ -0,0 +1,13 @@
+package ports
+
+import (
+	"context"
+	"video-processor-worker/internal/core/domain"
+)
+
+type EventPublisher interface {
+	// Publish announces a change in the processing of a video
+	Publish(ctx context.Context, event domain.VideoEvent) error
+	// Close flushes pending events and releases the connection
+	Close() error
+}
@@ -0,0 +1,12 @@
+package ports
+
+import "context"
+
+// JobExecutor is the Inbound Port that runs jobs on a bounded number of worker slots
+type JobExecutor interface {
+	// Submit waits for a free slot, then runs job in the background and hands
+	// its result to done. It returns ctx.Err() without running the job when ctx
+	// is done before a slot frees up.
+	Submit(ctx context.Context, job func(ctx context.Context) error, done func(err error)) error
+	FreeSlots() int
+}
@@ -0,0 +1,12 @@
+package ports
+
+import "context"
+
+// LeaderLock elects a single replica for work that must not run concurrently
+type LeaderLock interface {
+	// TryAcquire reports whether this replica holds the lock, taking it when
+	// it is free. It keeps returning true while the lock is held.
+	TryAcquire(ctx context.Context) (bool, error)
+	// Release gives the lock up so another replica can take it
+	Release(ctx context.Context) error
+}
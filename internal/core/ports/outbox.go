@@ -0,0 +1,30 @@
+package ports
+
+import (
+	"context"
+	"time"
+	"video-processor-worker/internal/core/domain"
+)
+
+// TxManager runs repository calls in a single transaction
+type TxManager interface {
+	// WithinTx commits when fn returns nil and rolls back otherwise. Repository
+	// calls made with the ctx given to fn join the transaction.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// OutboxRepository is the Outbound Port for events waiting to be published
+type OutboxRepository interface {
+	// Add records an event, call it within the transaction of the change it describes
+	Add(ctx context.Context, event domain.VideoEvent) error
+	// ClaimUnsent returns up to limit unsent entries that are due, oldest
+	// first, locked until the transaction ends so other relays skip them.
+	// Entries queued behind one of the same video that waits for a retry are
+	// left out.
+	ClaimUnsent(ctx context.Context, limit int) ([]domain.OutboxEntry, error)
+	MarkSent(ctx context.Context, id int64) error
+	// MarkFailed counts a failed publish of the entry and holds it back until retryAt
+	MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error
+	// Abandon gives the entry up after its last failed publish
+	Abandon(ctx context.Context, id int64, reason string) error
+}
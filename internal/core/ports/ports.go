@@ -3,6 +3,7 @@ package ports
 import (
 	"context"
 	"io"
+	"time"
 	"video-processor-worker/internal/core/domain"
 )
 
@@ -13,15 +14,37 @@ type VideoUseCase interface {
 	GetVideosByUserID(userID int64) ([]domain.Video, error)
 }
 
+// ProgressFunc receives progress updates while a video is being processed
+type ProgressFunc func(progress domain.Progress)
+
+// FrameSink receives each extracted frame, in order, as an encoded image
+type FrameSink func(name string, data []byte) error
+
 // VideoProcessor is the Outbound Port for video processing logic
 type VideoProcessor interface {
-	ExtractFrames(videoPath string, timestamp string) ([]string, error)
+	ExtractFrames(ctx context.Context, videoPath string, timestamp string, opts domain.ExtractionOptions, onProgress ProgressFunc) (domain.ExtractionResult, error)
+	// StreamFrames hands the frames to sink as they are decoded instead of
+	// writing them to disk
+	StreamFrames(ctx context.Context, videoPath string, opts domain.ExtractionOptions, onProgress ProgressFunc, sink FrameSink) (domain.ExtractionResult, error)
+}
+
+// VideoProbe is the Outbound Port for reading video metadata before processing
+type VideoProbe interface {
+	Probe(ctx context.Context, videoPath string) (*domain.VideoMetadata, error)
 }
 
 // Storage is the Outbound Port for file operations
 type Storage interface {
 	SaveUpload(filename string, data io.Reader) (string, error)
-	SaveZip(zipFilename string, files []string) error
+	// SaveArchive writes files, then the extra in-memory entries, into an
+	// output archive of format, one of domain.ArchiveFormats. The archive
+	// only becomes visible under name once it is complete.
+	SaveArchive(name, format string, files []string, extra ...ArchiveEntry) (domain.ArchiveInfo, error)
+	// CreateArchive starts an output archive that is filled one file at a time
+	CreateArchive(name, format string) (ArchiveWriter, error)
+	// FetchUpload makes the upload readable on local disk and returns its
+	// path. release frees any local copy; the upload itself is kept.
+	FetchUpload(ctx context.Context, filename string) (path string, release func(), err error)
 	DeleteFile(path string) error
 	DeleteDir(path string) error
 	ListOutputs() ([]domain.FileInfo, error)
@@ -29,11 +52,44 @@ type Storage interface {
 	GetUploadPath(filename string) string
 }
 
+// ArchiveEntry is an archive member that only exists in memory, such as a manifest
+type ArchiveEntry struct {
+	Name string
+	Data []byte
+}
+
+// ArchiveWriter builds an output archive. Close finishes and stores it,
+// Abort discards what was written so far.
+type ArchiveWriter interface {
+	AddFile(name string, data []byte) error
+	Close() (domain.ArchiveInfo, error)
+	Abort() error
+}
+
 // VideoRepository is the Outbound Port for video data persistence
 type VideoRepository interface {
+	// Update saves a video claimed by video.WorkerID. It fails with
+	// domain.ErrLostOwnership once the video was released or claimed by another worker.
 	Update(ctx context.Context, video *domain.Video) error
+	// UpdateProgress records the progress of a video claimed by workerID, it
+	// fails with domain.ErrLostOwnership like Update
+	UpdateProgress(ctx context.Context, id int64, workerID string, progress domain.Progress) error
 	GetByID(ctx context.Context, id int64) (*domain.Video, error)
-	GetPending(ctx context.Context) ([]domain.Video, error)
+	// GetPending returns up to limit due PENDING videos with an ID above afterID, by ID
+	GetPending(ctx context.Context, afterID int64, limit int) ([]domain.Video, error)
+	// Claim atomically moves a PENDING video whose retry backoff is over to
+	// PROCESSING for workerID. It returns nil when the video cannot be claimed.
+	Claim(ctx context.Context, id int64, workerID string) (*domain.Video, error)
+	// ClaimNextPending claims the oldest PENDING video not locked by another
+	// worker, returning nil when there is none.
+	ClaimNextPending(ctx context.Context, workerID string) (*domain.Video, error)
+	// Heartbeat records that workerID is still processing the video, it
+	// fails with domain.ErrLostOwnership like Update
+	Heartbeat(ctx context.Context, id int64, workerID string) error
+	// GetStale returns PROCESSING videos without a heartbeat or update for longer than staleAfter
+	GetStale(ctx context.Context, staleAfter time.Duration) ([]domain.Video, error)
+	// ReleaseStale moves a video that is still stale to status, reporting whether it did
+	ReleaseStale(ctx context.Context, id int64, status, message string, staleAfter time.Duration) (bool, error)
 }
 
 // UserUseCase is the Inbound Port for user logic
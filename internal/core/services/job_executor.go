@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	jobSlotsBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "worker_job_slots_busy",
+		Help: "Number of worker slots running a job",
+	})
+
+	jobSlotsIdle = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "worker_job_slots_idle",
+		Help: "Number of worker slots waiting for a job",
+	})
+)
+
+// ErrExecutorClosed is returned by Submit once Shutdown has started
+var ErrExecutorClosed = errors.New("job executor is shutting down")
+
+type jobExecutor struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	// Jobs run on their own context so a shutdown signal does not kill them
+	// before the grace period is over
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+
+	mu      sync.Mutex
+	closed  bool
+	closing chan struct{}
+}
+
+func NewJobExecutor(concurrency int) *jobExecutor {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	jobSlotsBusy.Set(0)
+	jobSlotsIdle.Set(float64(concurrency))
+
+	jobCtx, cancel := context.WithCancel(context.Background())
+	return &jobExecutor{
+		slots:      make(chan struct{}, concurrency),
+		jobCtx:     jobCtx,
+		cancelJobs: cancel,
+		closing:    make(chan struct{}),
+	}
+}
+
+func (e *jobExecutor) Submit(ctx context.Context, job func(ctx context.Context) error, done func(err error)) error {
+	// Blocking here is the back-pressure: callers stop pulling work while every slot is busy
+	select {
+	case e.slots <- struct{}{}:
+	case <-e.closing:
+		return ErrExecutorClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		<-e.slots
+		return ErrExecutorClosed
+	}
+	e.wg.Add(1)
+	e.mu.Unlock()
+
+	jobSlotsBusy.Inc()
+	jobSlotsIdle.Dec()
+
+	go func() {
+		defer func() {
+			<-e.slots
+			jobSlotsBusy.Dec()
+			jobSlotsIdle.Inc()
+			e.wg.Done()
+		}()
+
+		err := e.run(e.jobCtx, job)
+		if done != nil {
+			done(err)
+		}
+	}()
+	return nil
+}
+
+func (e *jobExecutor) FreeSlots() int {
+	return cap(e.slots) - len(e.slots)
+}
+
+// Wait blocks until every submitted job has finished
+func (e *jobExecutor) Wait() {
+	e.wg.Wait()
+}
+
+// Shutdown stops accepting jobs and waits for the running ones until ctx is
+// done. Jobs still running then are cancelled, and Shutdown waits for them to
+// clean up before returning ctx.Err().
+func (e *jobExecutor) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.closing)
+	}
+	e.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		e.cancelJobs()
+		return nil
+	case <-ctx.Done():
+		log.Printf("⏱️ Grace period over, cancelling %d running job(s)", len(e.slots))
+		e.cancelJobs()
+		<-drained
+		return ctx.Err()
+	}
+}
+
+// run turns a panicking job into an error so the slot is always released
+func (e *jobExecutor) run(ctx context.Context, job func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ Job panicked: %v", r)
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return job(ctx)
+}
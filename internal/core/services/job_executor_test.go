@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobExecutor(t *testing.T) {
+	t.Run("runs at most concurrency jobs at once", func(t *testing.T) {
+		executor := NewJobExecutor(2)
+		release := make(chan struct{})
+		var running, peak atomic.Int32
+
+		job := func(ctx context.Context) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+			return nil
+		}
+
+		ctx := context.Background()
+		assert.NoError(t, executor.Submit(ctx, job, nil))
+		assert.NoError(t, executor.Submit(ctx, job, nil))
+		assert.Equal(t, 0, executor.FreeSlots())
+
+		submitted := make(chan struct{})
+		go func() {
+			executor.Submit(ctx, job, nil)
+			close(submitted)
+		}()
+
+		select {
+		case <-submitted:
+			t.Fatal("submit should block while every slot is busy")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(release)
+		<-submitted
+		executor.Wait()
+
+		assert.Equal(t, int32(2), peak.Load())
+		assert.Equal(t, 2, executor.FreeSlots())
+	})
+
+	t.Run("submit gives up when context is done", func(t *testing.T) {
+		executor := NewJobExecutor(1)
+		release := make(chan struct{})
+		executor.Submit(context.Background(), func(ctx context.Context) error {
+			<-release
+			return nil
+		}, nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		var ran atomic.Bool
+		err := executor.Submit(ctx, func(ctx context.Context) error {
+			ran.Store(true)
+			return nil
+		}, nil)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		close(release)
+		executor.Wait()
+		assert.False(t, ran.Load())
+	})
+
+	t.Run("done receives job result", func(t *testing.T) {
+		executor := NewJobExecutor(1)
+		result := make(chan error, 1)
+
+		executor.Submit(context.Background(), func(ctx context.Context) error {
+			return errors.New("boom")
+		}, func(err error) { result <- err })
+
+		assert.EqualError(t, <-result, "boom")
+	})
+
+	t.Run("panicking job releases its slot", func(t *testing.T) {
+		executor := NewJobExecutor(1)
+		result := make(chan error, 1)
+
+		executor.Submit(context.Background(), func(ctx context.Context) error {
+			panic("bad frame")
+		}, func(err error) { result <- err })
+
+		assert.ErrorContains(t, <-result, "bad frame")
+		executor.Wait()
+		assert.Equal(t, 1, executor.FreeSlots())
+	})
+
+	t.Run("shutdown waits for running jobs", func(t *testing.T) {
+		executor := NewJobExecutor(2)
+		var finished atomic.Bool
+		executor.Submit(context.Background(), func(ctx context.Context) error {
+			time.Sleep(30 * time.Millisecond)
+			finished.Store(true)
+			return nil
+		}, nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := executor.Shutdown(ctx)
+
+		assert.NoError(t, err)
+		assert.True(t, finished.Load())
+	})
+
+	t.Run("shutdown cancels jobs after grace period", func(t *testing.T) {
+		executor := NewJobExecutor(1)
+		result := make(chan error, 1)
+		executor.Submit(context.Background(), func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, func(err error) { result <- err })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := executor.Shutdown(ctx)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, <-result, context.Canceled)
+	})
+
+	t.Run("submit is refused after shutdown", func(t *testing.T) {
+		executor := NewJobExecutor(1)
+		executor.Shutdown(context.Background())
+
+		err := executor.Submit(context.Background(), func(ctx context.Context) error { return nil }, nil)
+
+		assert.ErrorIs(t, err, ErrExecutorClosed)
+	})
+
+	t.Run("shutdown releases submitters waiting for a slot", func(t *testing.T) {
+		executor := NewJobExecutor(1)
+		executor.Submit(context.Background(), func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		}, nil)
+
+		result := make(chan error, 1)
+		go func() {
+			result <- executor.Submit(context.Background(), func(ctx context.Context) error { return nil }, nil)
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		executor.Shutdown(ctx)
+
+		assert.ErrorIs(t, <-result, ErrExecutorClosed)
+	})
+}
@@ -3,7 +3,9 @@ package services
 import (
 	"context"
 	"io"
+	"time"
 	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -12,9 +14,26 @@ type MockVideoProcessor struct {
 	mock.Mock
 }
 
-func (m *MockVideoProcessor) ExtractFrames(videoPath string, timestamp string) ([]string, error) {
-	args := m.Called(videoPath, timestamp)
-	return args.Get(0).([]string), args.Error(1)
+func (m *MockVideoProcessor) ExtractFrames(ctx context.Context, videoPath string, timestamp string, opts domain.ExtractionOptions, onProgress ports.ProgressFunc) (domain.ExtractionResult, error) {
+	args := m.Called(ctx, videoPath, timestamp, opts, onProgress)
+	return args.Get(0).(domain.ExtractionResult), args.Error(1)
+}
+
+func (m *MockVideoProcessor) StreamFrames(ctx context.Context, videoPath string, opts domain.ExtractionOptions, onProgress ports.ProgressFunc, sink ports.FrameSink) (domain.ExtractionResult, error) {
+	args := m.Called(ctx, videoPath, opts, onProgress, sink)
+	return args.Get(0).(domain.ExtractionResult), args.Error(1)
+}
+
+type MockVideoProbe struct {
+	mock.Mock
+}
+
+func (m *MockVideoProbe) Probe(ctx context.Context, videoPath string) (*domain.VideoMetadata, error) {
+	args := m.Called(ctx, videoPath)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.VideoMetadata), args.Error(1)
 }
 
 type MockStorage struct {
@@ -26,9 +45,22 @@ func (m *MockStorage) SaveUpload(filename string, data io.Reader) (string, error
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockStorage) SaveZip(zipFilename string, files []string) error {
-	args := m.Called(zipFilename, files)
-	return args.Error(0)
+func (m *MockStorage) SaveArchive(name, format string, files []string, extra ...ports.ArchiveEntry) (domain.ArchiveInfo, error) {
+	args := m.Called(name, format, files, extra)
+	return args.Get(0).(domain.ArchiveInfo), args.Error(1)
+}
+
+func (m *MockStorage) CreateArchive(name, format string) (ports.ArchiveWriter, error) {
+	args := m.Called(name, format)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(ports.ArchiveWriter), args.Error(1)
+}
+
+func (m *MockStorage) FetchUpload(ctx context.Context, filename string) (string, func(), error) {
+	args := m.Called(ctx, filename)
+	return args.String(0), func() {}, args.Error(1)
 }
 
 func (m *MockStorage) DeleteFile(path string) error {
@@ -56,6 +88,25 @@ func (m *MockStorage) GetUploadPath(filename string) string {
 	return args.String(0)
 }
 
+type MockArchiveWriter struct {
+	mock.Mock
+}
+
+func (m *MockArchiveWriter) AddFile(name string, data []byte) error {
+	args := m.Called(name, data)
+	return args.Error(0)
+}
+
+func (m *MockArchiveWriter) Close() (domain.ArchiveInfo, error) {
+	args := m.Called()
+	return args.Get(0).(domain.ArchiveInfo), args.Error(1)
+}
+
+func (m *MockArchiveWriter) Abort() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 type MockVideoRepository struct {
 	mock.Mock
 }
@@ -65,6 +116,11 @@ func (m *MockVideoRepository) Update(ctx context.Context, video *domain.Video) e
 	return args.Error(0)
 }
 
+func (m *MockVideoRepository) UpdateProgress(ctx context.Context, id int64, workerID string, progress domain.Progress) error {
+	args := m.Called(ctx, id, workerID, progress)
+	return args.Error(0)
+}
+
 func (m *MockVideoRepository) GetByID(ctx context.Context, id int64) (*domain.Video, error) {
 	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
@@ -73,11 +129,42 @@ func (m *MockVideoRepository) GetByID(ctx context.Context, id int64) (*domain.Vi
 	return args.Get(0).(*domain.Video), args.Error(1)
 }
 
-func (m *MockVideoRepository) GetPending(ctx context.Context) ([]domain.Video, error) {
-	args := m.Called(ctx)
+func (m *MockVideoRepository) GetPending(ctx context.Context, afterID int64, limit int) ([]domain.Video, error) {
+	args := m.Called(ctx, afterID, limit)
+	return args.Get(0).([]domain.Video), args.Error(1)
+}
+
+func (m *MockVideoRepository) Claim(ctx context.Context, id int64, workerID string) (*domain.Video, error) {
+	args := m.Called(ctx, id, workerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Video), args.Error(1)
+}
+
+func (m *MockVideoRepository) ClaimNextPending(ctx context.Context, workerID string) (*domain.Video, error) {
+	args := m.Called(ctx, workerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Video), args.Error(1)
+}
+
+func (m *MockVideoRepository) Heartbeat(ctx context.Context, id int64, workerID string) error {
+	args := m.Called(ctx, id, workerID)
+	return args.Error(0)
+}
+
+func (m *MockVideoRepository) GetStale(ctx context.Context, staleAfter time.Duration) ([]domain.Video, error) {
+	args := m.Called(ctx, staleAfter)
 	return args.Get(0).([]domain.Video), args.Error(1)
 }
 
+func (m *MockVideoRepository) ReleaseStale(ctx context.Context, id int64, status, message string, staleAfter time.Duration) (bool, error) {
+	args := m.Called(ctx, id, status, message, staleAfter)
+	return args.Bool(0), args.Error(1)
+}
+
 type MockUserRepository struct {
 	mock.Mock
 }
@@ -111,3 +198,70 @@ func (m *MockEmailSender) SendEmail(to, subject, body string) error {
 	args := m.Called(to, subject, body)
 	return args.Error(0)
 }
+
+type MockEventPublisher struct {
+	mock.Mock
+}
+
+func (m *MockEventPublisher) Publish(ctx context.Context, event domain.VideoEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockEventPublisher) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+// nopPublisher accepts any event, for tests that do not check events
+func nopPublisher() *MockEventPublisher {
+	publisher := new(MockEventPublisher)
+	publisher.On("Publish", mock.Anything, mock.Anything).Return(nil).Maybe()
+	return publisher
+}
+
+// fakeTx runs fn without a transaction
+type fakeTx struct{}
+
+func (fakeTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type MockOutboxRepository struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepository) Add(ctx context.Context, event domain.VideoEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) ClaimUnsent(ctx context.Context, limit int) ([]domain.OutboxEntry, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.OutboxEntry), args.Error(1)
+}
+
+func (m *MockOutboxRepository) MarkSent(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	args := m.Called(ctx, id, reason, retryAt)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) Abandon(ctx context.Context, id int64, reason string) error {
+	args := m.Called(ctx, id, reason)
+	return args.Error(0)
+}
+
+// nopOutbox accepts any event, for tests that do not check events
+func nopOutbox() *MockOutboxRepository {
+	outbox := new(MockOutboxRepository)
+	outbox.On("Add", mock.Anything, mock.Anything).Return(nil).Maybe()
+	return outbox
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var outboxEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "worker_outbox_events_total",
+	Help: "Total number of outbox events handled by the relay",
+}, []string{"outcome"})
+
+// OutboxRelayConfig holds the tunables of the outbox relay. A failed entry
+// is retried after RetryBaseDelay, doubled for every attempt up to
+// RetryMaxDelay, and given up after MaxAttempts.
+type OutboxRelayConfig struct {
+	BatchSize      int
+	MaxAttempts    int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+}
+
+// DefaultOutboxRelayConfig keeps retrying an entry for about an hour
+func DefaultOutboxRelayConfig() OutboxRelayConfig {
+	return OutboxRelayConfig{
+		BatchSize:      100,
+		MaxAttempts:    20,
+		RetryBaseDelay: time.Second,
+		RetryMaxDelay:  5 * time.Minute,
+	}
+}
+
+type outboxRelay struct {
+	tx        ports.TxManager
+	outbox    ports.OutboxRepository
+	publisher ports.EventPublisher
+	cfg       OutboxRelayConfig
+}
+
+func NewOutboxRelay(tx ports.TxManager, outbox ports.OutboxRepository, publisher ports.EventPublisher, cfg OutboxRelayConfig) *outboxRelay {
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = DefaultOutboxRelayConfig().BatchSize
+	}
+	return &outboxRelay{
+		tx:        tx,
+		outbox:    outbox,
+		publisher: publisher,
+		cfg:       cfg,
+	}
+}
+
+// Relay publishes a batch of unsent outbox entries in order and marks them
+// sent, returning how many were sent. An entry is marked only after the
+// broker accepted it, so a crash in between publishes it again: delivery is
+// at least once, and the event ID lets consumers drop the duplicate.
+func (r *outboxRelay) Relay(ctx context.Context) (int, error) {
+	sent := 0
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		entries, err := r.outbox.ClaimUnsent(ctx, r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		// Videos with a failed entry in this batch, their later events wait for it
+		failed := make(map[int64]bool)
+		for _, entry := range entries {
+			if failed[entry.Event.VideoID] {
+				continue
+			}
+			if err := r.publisher.Publish(ctx, entry.Event); err != nil {
+				failed[entry.Event.VideoID] = true
+				if err := r.fail(ctx, entry, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := r.outbox.MarkSent(ctx, entry.ID); err != nil {
+				return err
+			}
+			outboxEventsTotal.WithLabelValues("sent").Inc()
+			sent++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sent, nil
+}
+
+// fail schedules the next attempt of an entry that could not be published,
+// or gives it up once MaxAttempts is reached
+func (r *outboxRelay) fail(ctx context.Context, entry domain.OutboxEntry, cause error) error {
+	attempts := entry.Attempts + 1
+	if r.cfg.MaxAttempts > 0 && attempts >= r.cfg.MaxAttempts {
+		log.Printf("❌ Giving up outbox entry %d (%s) after %d attempts: %v", entry.ID, entry.Event.Type, attempts, cause)
+		outboxEventsTotal.WithLabelValues("abandoned").Inc()
+		return r.outbox.Abandon(ctx, entry.ID, cause.Error())
+	}
+
+	delay := r.backoff(attempts)
+	log.Printf("⚠️ Error relaying outbox entry %d (%s), retrying in %s: %v", entry.ID, entry.Event.Type, delay, cause)
+	outboxEventsTotal.WithLabelValues("error").Inc()
+	return r.outbox.MarkFailed(ctx, entry.ID, cause.Error(), time.Now().Add(delay))
+}
+
+// backoff doubles RetryBaseDelay for every attempt already made, capped at RetryMaxDelay
+func (r *outboxRelay) backoff(attempts int) time.Duration {
+	delay := r.cfg.RetryBaseDelay
+	for i := 1; i < attempts && delay < 24*time.Hour; i++ {
+		delay *= 2
+	}
+	if r.cfg.RetryMaxDelay > 0 && delay > r.cfg.RetryMaxDelay {
+		delay = r.cfg.RetryMaxDelay
+	}
+	return max(delay, 0)
+}
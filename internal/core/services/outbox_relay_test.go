@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"video-processor-worker/internal/core/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOutboxRelay_Relay(t *testing.T) {
+	ctx := context.Background()
+	entries := []domain.OutboxEntry{
+		{ID: 1, Event: domain.VideoEvent{Type: domain.EventProcessingStarted, VideoID: 7}},
+		{ID: 2, Event: domain.VideoEvent{Type: domain.EventProcessingCompleted, VideoID: 7}},
+		{ID: 3, Event: domain.VideoEvent{Type: domain.EventProcessingStarted, VideoID: 8}},
+	}
+
+	cfg := OutboxRelayConfig{BatchSize: 10, MaxAttempts: 3, RetryBaseDelay: time.Second, RetryMaxDelay: time.Minute}
+
+	t.Run("publishes and marks every entry", func(t *testing.T) {
+		outbox := new(MockOutboxRepository)
+		publisher := new(MockEventPublisher)
+		relay := NewOutboxRelay(fakeTx{}, outbox, publisher, cfg)
+
+		outbox.On("ClaimUnsent", ctx, 10).Return(entries, nil)
+		publisher.On("Publish", ctx, mock.Anything).Return(nil).Times(3)
+		outbox.On("MarkSent", ctx, mock.Anything).Return(nil).Times(3)
+
+		sent, err := relay.Relay(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, sent)
+		publisher.AssertExpectations(t)
+		outbox.AssertExpectations(t)
+	})
+
+	t.Run("a failed publish holds back only its own video", func(t *testing.T) {
+		outbox := new(MockOutboxRepository)
+		publisher := new(MockEventPublisher)
+		relay := NewOutboxRelay(fakeTx{}, outbox, publisher, cfg)
+
+		outbox.On("ClaimUnsent", ctx, 10).Return(entries, nil)
+		publisher.On("Publish", ctx, entries[0].Event).Return(errors.New("no responders"))
+		publisher.On("Publish", ctx, entries[2].Event).Return(nil)
+		outbox.On("MarkFailed", ctx, int64(1), "no responders", mock.MatchedBy(func(at time.Time) bool {
+			return time.Until(at) > 0 && time.Until(at) <= time.Second
+		})).Return(nil)
+		outbox.On("MarkSent", ctx, int64(3)).Return(nil)
+
+		sent, err := relay.Relay(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		publisher.AssertNotCalled(t, "Publish", ctx, entries[1].Event)
+		outbox.AssertExpectations(t)
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		outbox := new(MockOutboxRepository)
+		publisher := new(MockEventPublisher)
+		relay := NewOutboxRelay(fakeTx{}, outbox, publisher, cfg)
+
+		entry := entries[2]
+		entry.Attempts = cfg.MaxAttempts - 1
+		outbox.On("ClaimUnsent", ctx, 10).Return([]domain.OutboxEntry{entry}, nil)
+		publisher.On("Publish", ctx, entry.Event).Return(errors.New("no responders"))
+		outbox.On("Abandon", ctx, int64(3), "no responders").Return(nil)
+
+		sent, err := relay.Relay(ctx)
+
+		assert.NoError(t, err)
+		assert.Zero(t, sent)
+		outbox.AssertExpectations(t)
+		outbox.AssertNotCalled(t, "MarkFailed", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("nothing to relay", func(t *testing.T) {
+		outbox := new(MockOutboxRepository)
+		relay := NewOutboxRelay(fakeTx{}, outbox, nil, cfg)
+
+		outbox.On("ClaimUnsent", ctx, 10).Return(nil, nil)
+
+		sent, err := relay.Relay(ctx)
+
+		assert.NoError(t, err)
+		assert.Zero(t, sent)
+	})
+}
+
+func TestOutboxRelay_Backoff(t *testing.T) {
+	relay := NewOutboxRelay(fakeTx{}, nil, nil, OutboxRelayConfig{RetryBaseDelay: time.Second, RetryMaxDelay: 10 * time.Second})
+
+	for attempts, delay := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 50: 10 * time.Second} {
+		assert.Equal(t, delay, relay.backoff(attempts), "attempt %d", attempts)
+	}
+}
@@ -2,9 +2,13 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand/v2"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 	"video-processor-worker/internal/core/domain"
@@ -25,46 +29,197 @@ var (
 		Name: "worker_videos_processed_total",
 		Help: "Total number of videos processed",
 	}, []string{"status"})
+
+	videoProgressPercent = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "worker_video_progress_percent",
+		Help: "Extraction progress of in-flight videos",
+	}, []string{"video_id"})
+
+	videoProgressETA = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "worker_video_progress_eta_seconds",
+		Help: "Estimated seconds until in-flight videos finish extraction",
+	}, []string{"video_id"})
+
+	videosReapedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_videos_reaped_total",
+		Help: "Total number of abandoned PROCESSING videos recovered by the reaper",
+	}, []string{"outcome"})
 )
 
+// Config holds the tunables of the worker service
+type Config struct {
+	// WorkerID is recorded on the videos this worker claims
+	WorkerID string
+
+	// The ffmpeg deadline of a job is JobTimeoutBase plus JobTimeoutFactor times
+	// the duration of the selected range, capped at JobTimeoutMax.
+	JobTimeoutBase   time.Duration
+	JobTimeoutFactor float64
+	JobTimeoutMax    time.Duration
+
+	// Minimum time between two progress writes to the repository
+	ProgressInterval time.Duration
+
+	// Running jobs heartbeat every HeartbeatInterval; a PROCESSING video
+	// without a sign of life for StaleAfter is requeued, or failed once it
+	// has been attempted MaxAttempts times
+	HeartbeatInterval time.Duration
+	StaleAfter        time.Duration
+	MaxAttempts       int
+
+	// Retryable failures are attempted again after an exponential backoff
+	// starting at RetryBaseDelay and capped at RetryMaxDelay, with jitter
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// StreamFrames writes frames straight into the archive as ffmpeg decodes
+	// them instead of going through a temp frame directory
+	StreamFrames bool
+
+	// ArchiveFormat is used for videos whose options do not pick one
+	ArchiveFormat string
+
+	// ManifestCSV adds a CSV copy of the frame list next to manifest.json
+	ManifestCSV bool
+}
+
+func DefaultConfig() Config {
+	hostname, _ := os.Hostname()
+	return Config{
+		WorkerID:          hostname,
+		JobTimeoutBase:    2 * time.Minute,
+		JobTimeoutFactor:  3,
+		JobTimeoutMax:     2 * time.Hour,
+		ProgressInterval:  5 * time.Second,
+		HeartbeatInterval: 30 * time.Second,
+		StaleAfter:        5 * time.Minute,
+		MaxAttempts:       3,
+		RetryBaseDelay:    30 * time.Second,
+		RetryMaxDelay:     10 * time.Minute,
+		ArchiveFormat:     domain.DefaultArchiveFormat,
+	}
+}
+
 type workerService struct {
 	processor ports.VideoProcessor
+	probe     ports.VideoProbe
 	storage   ports.Storage
 	repo      ports.VideoRepository
 	userRepo  ports.UserRepository
 	emailer   ports.EmailSender
+	publisher ports.EventPublisher
+	tx        ports.TxManager
+	outbox    ports.OutboxRepository
+	cfg       Config
+}
+
+// Dependencies are the ports the worker service drives
+type Dependencies struct {
+	Processor ports.VideoProcessor
+	Probe     ports.VideoProbe
+	Storage   ports.Storage
+	Videos    ports.VideoRepository
+	Users     ports.UserRepository
+	Emailer   ports.EmailSender
+	Publisher ports.EventPublisher
+	Tx        ports.TxManager
+	Outbox    ports.OutboxRepository
 }
 
-func NewWorkerService(p ports.VideoProcessor, s ports.Storage, r ports.VideoRepository, ur ports.UserRepository, e ports.EmailSender) *workerService {
+// NewWorkerService builds the worker. Lifecycle events go through the outbox
+// in the transaction of the status change they report; progress events are
+// published right away.
+func NewWorkerService(deps Dependencies, cfg Config) *workerService {
 	return &workerService{
-		processor: p,
-		storage:   s,
-		repo:      r,
-		userRepo:  ur,
-		emailer:   e,
+		processor: deps.Processor,
+		probe:     deps.Probe,
+		storage:   deps.Storage,
+		repo:      deps.Videos,
+		userRepo:  deps.Users,
+		emailer:   deps.Emailer,
+		publisher: deps.Publisher,
+		tx:        deps.Tx,
+		outbox:    deps.Outbox,
+		cfg:       cfg,
 	}
 }
 
 func (s *workerService) ProcessVideoByID(ctx context.Context, videoID int64) error {
+	return s.ProcessVideoWithOptions(ctx, videoID, nil)
+}
+
+// ProcessVideoWithOptions processes a pending video. Options carried by the
+// triggering event take precedence over the ones stored on the video row.
+func (s *workerService) ProcessVideoWithOptions(ctx context.Context, videoID int64, opts *domain.ExtractionOptions) error {
 	log.Printf("📥 Processing request for video ID: %d", videoID)
 
-	video, err := s.repo.GetByID(ctx, videoID)
+	video, err := s.claim(ctx, func(ctx context.Context) (*domain.Video, error) {
+		return s.repo.Claim(ctx, videoID, s.cfg.WorkerID)
+	})
 	if err != nil {
-		return fmt.Errorf("error fetching video %d: %w", videoID, err)
+		return &domain.RetryError{Err: fmt.Errorf("error claiming video %d: %w", videoID, err), Delay: s.backoff(1)}
 	}
 
 	if video == nil {
-		return fmt.Errorf("video %d not found", videoID)
+		// Not claimable: tell a missing video apart from one another worker owns or finished
+		existing, err := s.repo.GetByID(ctx, videoID)
+		if err != nil {
+			return &domain.RetryError{Err: fmt.Errorf("error fetching video %d: %w", videoID, err), Delay: s.backoff(1)}
+		}
+		if existing == nil {
+			return fmt.Errorf("video %d not found", videoID)
+		}
+		if existing.Status == domain.StatusPending && existing.NextAttemptAt != nil {
+			// Redelivered before its backoff is over
+			wait := time.Until(*existing.NextAttemptAt)
+			log.Printf("⏳ Video %d is not due for another %s", videoID, wait.Round(time.Second))
+			return &domain.RetryError{Err: fmt.Errorf("video %d not due yet", videoID), Delay: max(wait, 0)}
+		}
+		if existing.Status == domain.StatusProcessing && s.ownerLooksDead(existing) {
+			// Its worker probably died: come back once the reaper has requeued it
+			log.Printf("⏳ Video %d is held by silent worker %q, retrying in %s", videoID, existing.WorkerID, s.cfg.StaleAfter)
+			return &domain.RetryError{Err: fmt.Errorf("video %d held by a stale worker", videoID), Delay: s.cfg.StaleAfter}
+		}
+		log.Printf("ℹ️ Video %d already in status %s, skipping", videoID, existing.Status)
+		return nil
 	}
 
-	if video.Status != domain.StatusPending {
-		log.Printf("ℹ️ Video %d already in status %s, skipping", videoID, video.Status)
-		return nil
+	if opts != nil {
+		video.Options = *opts
 	}
+	video.Options = video.Options.Normalize()
 
 	return s.processVideo(ctx, video)
 }
 
+// ProcessNextPending claims and processes the oldest pending video. It
+// reports whether there was one.
+func (s *workerService) ProcessNextPending(ctx context.Context) (bool, error) {
+	video, err := s.claim(ctx, func(ctx context.Context) (*domain.Video, error) {
+		return s.repo.ClaimNextPending(ctx, s.cfg.WorkerID)
+	})
+	if err != nil {
+		return false, fmt.Errorf("error claiming next pending video: %w", err)
+	}
+	if video == nil {
+		return false, nil
+	}
+
+	log.Printf("📥 Claimed pending video ID: %d", video.ID)
+	video.Options = video.Options.Normalize()
+	return true, s.processVideo(ctx, video)
+}
+
+// PendingVideos pages through the videos waiting to be processed, see
+// ports.VideoRepository.GetPending
+func (s *workerService) PendingVideos(ctx context.Context, afterID int64, limit int) ([]domain.Video, error) {
+	videos, err := s.repo.GetPending(ctx, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pending videos: %w", err)
+	}
+	return videos, nil
+}
+
 func (s *workerService) processVideo(ctx context.Context, video *domain.Video) error {
 	start := time.Now()
 	var status = "success"
@@ -75,45 +230,122 @@ func (s *workerService) processVideo(ctx context.Context, video *domain.Video) e
 		videosProcessedTotal.WithLabelValues(status).Inc()
 	}()
 
-	// Update status to PROCESSING
-	video.Status = domain.StatusProcessing
-	video.Message = "Processamento iniciado..."
+	// Cancelled with domain.ErrLostOwnership once the heartbeat or a progress
+	// update finds the video taken over, so extraction stops early
+	owned, lose := context.WithCancelCause(ctx)
+	defer lose(nil)
+
+	// The claim already moved the video to PROCESSING
+	stopHeartbeat := s.startHeartbeat(ctx, video.ID, lose)
+	defer stopHeartbeat()
+
+	uploadPath := s.storage.GetUploadPath(video.Filename)
+	uniqueJobID := strings.TrimSuffix(video.Filename, filepath.Ext(video.Filename))
+
+	videoPath, release, err := s.storage.FetchUpload(ctx, video.Filename)
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("⚠️ Fetching upload of video %d interrupted: %v", video.ID, err)
+			status = "interrupted"
+			return s.requeueInterrupted(ctx, video, err)
+		}
+		log.Printf("❌ Error fetching upload of video %d: %v", video.ID, err)
+		return s.handleFailure(ctx, video, uploadPath, "Erro ao obter o vídeo enviado.", err, &status)
+	}
+	defer release()
+
+	log.Printf("🔎 Probing video ID: %d (%s)", video.ID, video.Filename)
+	metadata, err := s.probe.Probe(ctx, videoPath)
+	if err == nil {
+		err = metadata.Validate()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("⚠️ Probe for video %d interrupted: %v", video.ID, err)
+			status = "interrupted"
+			return s.requeueInterrupted(ctx, video, err)
+		}
+		log.Printf("❌ Error probing video %d: %v", video.ID, err)
+		return s.handleFailure(ctx, video, uploadPath, "Vídeo inválido ou não suportado: "+err.Error(), err, &status)
+	}
+
+	video.Metadata = metadata
 	if err := s.repo.Update(ctx, video); err != nil {
-		return fmt.Errorf("error updating video status: %w", err)
+		log.Printf("❌ Error saving metadata for video %d: %v", video.ID, err)
+		err = fmt.Errorf("error saving video metadata: %w", err)
+		return s.handleFailure(ctx, video, uploadPath, "Erro ao salvar metadados do vídeo.", err, &status)
 	}
 
-	videoPath := s.storage.GetUploadPath(video.Filename)
-	uniqueJobID := strings.TrimSuffix(video.Filename, filepath.Ext(video.Filename))
+	archiveFormat := s.archiveFormat(video)
+	archiveName := "frames_" + uniqueJobID + domain.ArchiveExtension(archiveFormat)
+
+	var archive ports.ArchiveWriter
+	if s.cfg.StreamFrames {
+		archive, err = s.storage.CreateArchive(archiveName, archiveFormat)
+		if err != nil {
+			log.Printf("❌ Error creating archive for video %d: %v", video.ID, err)
+			return s.handleFailure(ctx, video, uploadPath, "Erro ao criar arquivo compactado: "+err.Error(), err, &status)
+		}
+	}
 
-	log.Printf("🎬 Extracting frames for video ID: %d (%s)", video.ID, video.Filename)
-	frames, err := s.processor.ExtractFrames(videoPath, uniqueJobID)
+	timeout := s.jobTimeout(video)
+	log.Printf("🎬 Extracting frames for video ID: %d (%s), timeout %s", video.ID, video.Filename, timeout)
+	jobCtx, cancel := context.WithTimeout(owned, timeout)
+	var result domain.ExtractionResult
+	if archive != nil {
+		result, err = s.processor.StreamFrames(jobCtx, videoPath, video.Options, s.progressReporter(ctx, video, lose), archive.AddFile)
+	} else {
+		result, err = s.processor.ExtractFrames(jobCtx, videoPath, uniqueJobID, video.Options, s.progressReporter(ctx, video, lose))
+	}
+	cancel()
+	s.clearProgress(video.ID)
+	if err != nil && archive != nil {
+		archive.Abort()
+	}
 	if err != nil {
+		if lost := context.Cause(owned); errors.Is(lost, domain.ErrLostOwnership) {
+			return s.handleFailure(ctx, video, uploadPath, "", lost, &status)
+		}
+		if ctx.Err() != nil {
+			log.Printf("⚠️ Extraction for video %d interrupted: %v", video.ID, err)
+			status = "interrupted"
+			return s.requeueInterrupted(ctx, video, err)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Printf("⏱️ Extraction for video %d timed out after %s", video.ID, timeout)
+			status = "timeout"
+			err = fmt.Errorf("%w after %s: %v", domain.ErrProcessingTimeout, timeout, err)
+			return s.handleFailure(ctx, video, uploadPath, fmt.Sprintf("Tempo limite de processamento excedido (%s).", timeout), err, nil)
+		}
 		log.Printf("❌ Error extracting frames for video %d: %v", video.ID, err)
-		video.Status = domain.StatusFailed
-		video.Message = "Erro no processamento: " + err.Error()
-		s.repo.Update(ctx, video)
-		s.storage.DeleteFile(videoPath)
-		s.notifyFailure(ctx, video)
-		status = "error"
-		return err
-	}
-
-	log.Printf("📦 Creating ZIP for video ID: %d", video.ID)
-	zipFilename := fmt.Sprintf("frames_%s.zip", uniqueJobID)
-	err = s.storage.SaveZip(zipFilename, frames)
+		return s.handleFailure(ctx, video, uploadPath, "Erro no processamento: "+err.Error(), err, &status)
+	}
+
+	frames := result.Frames
+	frameCount := len(frames)
+
+	var stored domain.ArchiveInfo
+	manifest, err := s.manifestEntries(video, result, archiveFormat)
+	if archive != nil {
+		log.Printf("📦 Finishing %s for video ID: %d", archiveFormat, video.ID)
+		frameCount = result.FrameCount
+		for i := 0; err == nil && i < len(manifest); i++ {
+			err = archive.AddFile(manifest[i].Name, manifest[i].Data)
+		}
+		if err != nil {
+			archive.Abort()
+		} else {
+			stored, err = archive.Close()
+		}
+	} else if err == nil {
+		log.Printf("📦 Creating %s for video ID: %d", archiveFormat, video.ID)
+		stored, err = s.storage.SaveArchive(archiveName, archiveFormat, frames, manifest...)
+	}
 	if err != nil {
-		log.Printf("❌ Error saving ZIP for video %d: %v", video.ID, err)
-		video.Status = domain.StatusFailed
-		video.Message = "Erro ao criar ZIP: " + err.Error()
-		s.repo.Update(ctx, video)
-		s.storage.DeleteFile(videoPath)
-		s.notifyFailure(ctx, video)
-		status = "error"
-		return err
-	}
-
-	// Cleanup
-	s.storage.DeleteFile(videoPath)
+		log.Printf("❌ Error saving archive for video %d: %v", video.ID, err)
+		return s.handleFailure(ctx, video, uploadPath, "Erro ao criar arquivo compactado: "+err.Error(), err, &status)
+	}
+
 	if len(frames) > 0 {
 		tempDir := filepath.Dir(frames[0])
 		s.storage.DeleteDir(tempDir)
@@ -121,18 +353,349 @@ func (s *workerService) processVideo(ctx context.Context, video *domain.Video) e
 
 	// Final Update
 	video.Status = domain.StatusCompleted
-	video.ZipPath = zipFilename
-	video.FrameCount = len(frames)
-	video.Message = fmt.Sprintf("Processamento concluído! %d frames extraídos.", len(frames))
-	if err := s.repo.Update(ctx, video); err != nil {
+	video.ZipPath = archiveName
+	video.ArchiveFormat = archiveFormat
+	video.ArchiveExt = domain.ArchiveExtension(archiveFormat)
+	video.ArchiveSize = stored.Size
+	video.ArchiveSHA256 = stored.SHA256
+	video.FrameCount = frameCount
+	video.ExtractionMode = result.Mode
+	video.Progress = domain.Progress{Percent: 100, Frames: frameCount}
+	video.Message = fmt.Sprintf("Processamento concluído! %d frames extraídos.", frameCount)
+	video.LastError = ""
+	if err := s.saveWithEvent(ctx, video, domain.EventProcessingCompleted); err != nil {
 		log.Printf("❌ Error final updating video %d: %v", video.ID, err)
-		return err
+		video.ZipPath = ""
+		video.ArchiveFormat = ""
+		video.ArchiveExt = ""
+		video.ArchiveSize = 0
+		video.ArchiveSHA256 = ""
+		video.FrameCount = 0
+		return s.handleFailure(ctx, video, uploadPath, "Erro ao finalizar o processamento.", err, &status)
 	}
 
+	// The upload is kept until the video is COMPLETED so a retry can start over
+	s.storage.DeleteFile(uploadPath)
+
 	log.Printf("✅ Video %d processed successfully", video.ID)
 	return nil
 }
 
+// manifestEntries renders the frame manifest stored alongside the frames
+func (s *workerService) manifestEntries(video *domain.Video, result domain.ExtractionResult, archiveFormat string) ([]ports.ArchiveEntry, error) {
+	manifest := domain.NewFrameManifest(video, result, archiveFormat)
+	data, err := manifest.JSON()
+	if err != nil {
+		return nil, fmt.Errorf("error encoding frame manifest: %w", err)
+	}
+	entries := []ports.ArchiveEntry{{Name: domain.ManifestFilename, Data: data}}
+
+	if s.cfg.ManifestCSV {
+		data, err := manifest.CSV()
+		if err != nil {
+			return nil, fmt.Errorf("error encoding frame manifest: %w", err)
+		}
+		entries = append(entries, ports.ArchiveEntry{Name: domain.ManifestCSVFilename, Data: data})
+	}
+	return entries, nil
+}
+
+// archiveFormat returns the archive format picked by the video's options,
+// falling back to the configured one
+func (s *workerService) archiveFormat(video *domain.Video) string {
+	if format := domain.ParseArchiveFormat(video.Options.ArchiveFormat); format != "" {
+		return format
+	}
+	if format := domain.ParseArchiveFormat(s.cfg.ArchiveFormat); format != "" {
+		return format
+	}
+	return domain.DefaultArchiveFormat
+}
+
+// startHeartbeat keeps the video's heartbeat fresh until the returned func is
+// called. It calls lose when the video turns out to be taken over.
+func (s *workerService) startHeartbeat(ctx context.Context, videoID int64, lose context.CancelCauseFunc) func() {
+	if s.cfg.HeartbeatInterval <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.cfg.HeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := s.repo.Heartbeat(ctx, videoID, s.cfg.WorkerID)
+				if errors.Is(err, domain.ErrLostOwnership) {
+					log.Printf("⚠️ Video %d was taken over, stopping its job", videoID)
+					lose(err)
+					return
+				}
+				if err != nil && ctx.Err() == nil {
+					log.Printf("⚠️ Error sending heartbeat for video %d: %v", videoID, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// ownerLooksDead reports whether a PROCESSING video has missed at least two
+// heartbeats, so its claim will likely be released by the reaper
+func (s *workerService) ownerLooksDead(video *domain.Video) bool {
+	silence := 2 * s.cfg.HeartbeatInterval
+	if silence <= 0 {
+		silence = s.cfg.StaleAfter
+	}
+	lastSeen := video.UpdatedAt
+	if video.HeartbeatAt != nil && video.HeartbeatAt.After(lastSeen) {
+		lastSeen = *video.HeartbeatAt
+	}
+	return time.Since(lastSeen) > silence
+}
+
+// ReapStaleVideos recovers videos left in PROCESSING by a worker that died:
+// they go back to PENDING, or to FAILED once MaxAttempts is reached. It
+// returns how many videos were recovered.
+func (s *workerService) ReapStaleVideos(ctx context.Context) (int, error) {
+	videos, err := s.repo.GetStale(ctx, s.cfg.StaleAfter)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching stale videos: %w", err)
+	}
+
+	reaped := 0
+	for i := range videos {
+		video := &videos[i]
+
+		outcome := "requeued"
+		video.Status = domain.StatusPending
+		video.Message = "Processamento abandonado, aguardando nova tentativa."
+		if s.cfg.MaxAttempts > 0 && video.Attempts >= s.cfg.MaxAttempts {
+			outcome = "failed"
+			video.Status = domain.StatusFailed
+			video.Message = fmt.Sprintf("Processamento abandonado após %d tentativas.", video.Attempts)
+		}
+
+		var ok bool
+		err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+			var err error
+			ok, err = s.repo.ReleaseStale(ctx, video.ID, video.Status, video.Message, s.cfg.StaleAfter)
+			if err != nil || !ok || video.Status != domain.StatusFailed {
+				return err
+			}
+			return s.outbox.Add(ctx, domain.NewVideoEvent(domain.EventProcessingFailed, video))
+		})
+		if err != nil {
+			log.Printf("❌ Error releasing stale video %d: %v", video.ID, err)
+			continue
+		}
+		if !ok {
+			// A late heartbeat or another reaper got there first
+			continue
+		}
+
+		log.Printf("🧹 Reaped stale video %d (attempt %d): %s", video.ID, video.Attempts, outcome)
+		videosReapedTotal.WithLabelValues(outcome).Inc()
+		reaped++
+
+		if video.Status == domain.StatusFailed {
+			s.storage.DeleteFile(s.storage.GetUploadPath(video.Filename))
+			s.notifyFailure(ctx, video)
+		}
+	}
+	return reaped, nil
+}
+
+// progressReporter exports every update as metrics, and writes and publishes
+// at most one update per ProgressInterval. It calls lose when the video turns
+// out to be taken over.
+func (s *workerService) progressReporter(ctx context.Context, video *domain.Video, lose context.CancelCauseFunc) ports.ProgressFunc {
+	videoID := video.ID
+	label := strconv.FormatInt(videoID, 10)
+	var lastWrite time.Time
+
+	return func(progress domain.Progress) {
+		videoProgressPercent.WithLabelValues(label).Set(progress.Percent)
+		videoProgressETA.WithLabelValues(label).Set(progress.ETASeconds)
+
+		if time.Since(lastWrite) < s.cfg.ProgressInterval {
+			return
+		}
+		lastWrite = time.Now()
+
+		err := s.repo.UpdateProgress(ctx, videoID, s.cfg.WorkerID, progress)
+		if errors.Is(err, domain.ErrLostOwnership) {
+			log.Printf("⚠️ Video %d was taken over, stopping its job", videoID)
+			lose(err)
+			return
+		}
+		if err != nil {
+			log.Printf("⚠️ Error saving progress for video %d: %v", videoID, err)
+		}
+
+		event := domain.NewVideoEvent(domain.EventProcessingProgress, video)
+		event.Progress = &progress
+		if err := s.publisher.Publish(ctx, event); err != nil {
+			log.Printf("⚠️ Error publishing progress of video %d: %v", videoID, err)
+		}
+	}
+}
+
+func (s *workerService) clearProgress(videoID int64) {
+	label := strconv.FormatInt(videoID, 10)
+	videoProgressPercent.DeleteLabelValues(label)
+	videoProgressETA.DeleteLabelValues(label)
+}
+
+// jobTimeout scales the ffmpeg deadline with the amount of video that will be decoded
+func (s *workerService) jobTimeout(video *domain.Video) time.Duration {
+	end := 0.0
+	if video.Metadata != nil {
+		end = video.Metadata.Duration
+	}
+	if video.Options.EndTime > 0 && video.Options.EndTime < end {
+		end = video.Options.EndTime
+	}
+	seconds := end - video.Options.StartTime
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	timeout := s.cfg.JobTimeoutBase + time.Duration(seconds*s.cfg.JobTimeoutFactor*float64(time.Second))
+	if s.cfg.JobTimeoutMax > 0 && timeout > s.cfg.JobTimeoutMax {
+		timeout = s.cfg.JobTimeoutMax
+	}
+	return timeout
+}
+
+// requeueInterrupted puts a video cut short by a shutdown back to PENDING so
+// another replica can pick it up right away. The attempt counted by the
+// claim is given back, the video did not fail. ctx is already cancelled at
+// this point.
+func (s *workerService) requeueInterrupted(ctx context.Context, video *domain.Video, cause error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	video.Status = domain.StatusPending
+	video.Attempts = max(video.Attempts-1, 0)
+	video.Message = "Processamento interrompido, aguardando nova tentativa."
+	video.Progress = domain.Progress{}
+	video.NextAttemptAt = nil
+	if err := s.repo.Update(ctx, video); err != nil {
+		log.Printf("❌ Error requeueing interrupted video %d: %v", video.ID, err)
+	}
+	return &domain.RetryError{Err: cause}
+}
+
+// handleFailure sends a video whose attempt failed back to PENDING with a
+// backoff when the error is retryable and attempts remain, and fails it
+// otherwise. status is set to the metric label of the outcome when not nil.
+func (s *workerService) handleFailure(ctx context.Context, video *domain.Video, uploadPath string, message string, cause error, status *string) error {
+	if errors.Is(cause, domain.ErrLostOwnership) {
+		// Whoever owns the video now is in charge of it and of its upload
+		log.Printf("⚠️ Video %d was taken over while processing, dropping this attempt", video.ID)
+		if status != nil {
+			*status = "lost"
+		}
+		return nil
+	}
+
+	video.LastError = cause.Error()
+
+	if domain.IsPermanent(cause) || (s.cfg.MaxAttempts > 0 && video.Attempts >= s.cfg.MaxAttempts) {
+		if status != nil {
+			*status = "error"
+		}
+		s.failVideo(ctx, video, uploadPath, message)
+		return cause
+	}
+
+	delay := s.backoff(video.Attempts)
+	next := time.Now().Add(delay)
+	video.Status = domain.StatusPending
+	video.Message = fmt.Sprintf("%s. Nova tentativa em %s.", strings.TrimSuffix(message, "."), delay.Round(time.Second))
+	video.Progress = domain.Progress{}
+	video.NextAttemptAt = &next
+	if err := s.repo.Update(ctx, video); err != nil {
+		log.Printf("❌ Error scheduling retry of video %d: %v", video.ID, err)
+	}
+
+	log.Printf("🔁 Attempt %d of video %d failed, retrying in %s: %v", video.Attempts, video.ID, delay.Round(time.Second), cause)
+	if status != nil {
+		*status = "retry"
+	}
+	return &domain.RetryError{Err: cause, Delay: delay}
+}
+
+// backoff doubles RetryBaseDelay for every attempt already made, capped at
+// RetryMaxDelay. Half of the delay is randomized so retries of videos that
+// failed together spread out.
+func (s *workerService) backoff(attempts int) time.Duration {
+	delay := s.cfg.RetryBaseDelay
+	for i := 1; i < attempts && delay < 24*time.Hour; i++ {
+		delay *= 2
+	}
+	if s.cfg.RetryMaxDelay > 0 && delay > s.cfg.RetryMaxDelay {
+		delay = s.cfg.RetryMaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// failVideo marks the video as FAILED, removes the upload and notifies the owner
+func (s *workerService) failVideo(ctx context.Context, video *domain.Video, uploadPath string, message string) {
+	video.Status = domain.StatusFailed
+	video.Message = message
+	if err := s.saveWithEvent(ctx, video, domain.EventProcessingFailed); err != nil {
+		log.Printf("❌ Error saving failure of video %d: %v", video.ID, err)
+		if errors.Is(err, domain.ErrLostOwnership) {
+			return
+		}
+	}
+	s.storage.DeleteFile(uploadPath)
+	s.notifyFailure(ctx, video)
+}
+
+// claim runs claimFn and records the started event in the same transaction
+func (s *workerService) claim(ctx context.Context, claimFn func(ctx context.Context) (*domain.Video, error)) (*domain.Video, error) {
+	var video *domain.Video
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		video, err = claimFn(ctx)
+		if err != nil || video == nil {
+			return err
+		}
+		return s.outbox.Add(ctx, domain.NewVideoEvent(domain.EventProcessingStarted, video))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return video, nil
+}
+
+// saveWithEvent updates the video and records eventType in the same transaction
+func (s *workerService) saveWithEvent(ctx context.Context, video *domain.Video, eventType string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Update(ctx, video); err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, domain.NewVideoEvent(eventType, video))
+	})
+}
+
 func (s *workerService) notifyFailure(ctx context.Context, video *domain.Video) {
 	log.Printf("📧 Initiating failure notification for video %d (User %d)", video.ID, video.UserID)
 
@@ -2,26 +2,91 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
+func sampleMetadata() *domain.VideoMetadata {
+	return &domain.VideoMetadata{Container: "mov,mp4", Duration: 12.5, Size: 1024, Codec: "h264", Width: 1920, Height: 1080, FrameRate: 30}
+}
+
+func sampleArchive() domain.ArchiveInfo {
+	return domain.ArchiveInfo{Size: 2048, SHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}
+}
+
+// testMocks are the ports of a service built by newTestService
+type testMocks struct {
+	processor *MockVideoProcessor
+	probe     *MockVideoProbe
+	storage   *MockStorage
+	repo      *MockVideoRepository
+	users     *MockUserRepository
+	emailer   *MockEmailSender
+}
+
+// newTestService wires a worker service to fresh mocks. Events go to a
+// publisher and an outbox that accept anything.
+func newTestService(cfg Config) (*workerService, *testMocks) {
+	m := &testMocks{
+		processor: new(MockVideoProcessor),
+		probe:     new(MockVideoProbe),
+		storage:   new(MockStorage),
+		repo:      new(MockVideoRepository),
+		users:     new(MockUserRepository),
+		emailer:   new(MockEmailSender),
+	}
+	service := NewWorkerService(Dependencies{
+		Processor: m.processor,
+		Probe:     m.probe,
+		Storage:   m.storage,
+		Videos:    m.repo,
+		Users:     m.users,
+		Emailer:   m.emailer,
+		Publisher: nopPublisher(),
+		Tx:        fakeTx{},
+		Outbox:    nopOutbox(),
+	}, cfg)
+	return service, m
+}
+
+// expectUpload lets video be claimed and its upload be fetched from /uploads
+func (m *testMocks) expectUpload(ctx context.Context, video *domain.Video) {
+	path := "/uploads/" + video.Filename
+	m.repo.On("Claim", ctx, video.ID, mock.Anything).Return(video, nil)
+	m.storage.On("GetUploadPath", video.Filename).Return(path)
+	m.storage.On("FetchUpload", mock.Anything, video.Filename).Return(path, nil)
+}
+
+// expectProbed is expectUpload for an upload that probes as sampleMetadata
+func (m *testMocks) expectProbed(ctx context.Context, video *domain.Video) {
+	m.expectUpload(ctx, video)
+	m.probe.On("Probe", ctx, "/uploads/"+video.Filename).Return(sampleMetadata(), nil)
+}
+
+// expectArchived accepts the ZIP of frames and the cleanup that follows it
+func (m *testMocks) expectArchived(frames ...string) {
+	m.storage.On("SaveArchive", "frames_video.zip", domain.ArchiveFormatZip, frames, mock.Anything).Return(sampleArchive(), nil)
+	m.storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
+	m.storage.On("DeleteDir", "/tmp").Return(nil)
+}
+
 func TestWorkerService_ProcessVideoByID(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("video not found", func(t *testing.T) {
-		processor := new(MockVideoProcessor)
-		storage := new(MockStorage)
-		repo := new(MockVideoRepository)
-		userRepo := new(MockUserRepository)
-		emailer := new(MockEmailSender)
-		service := NewWorkerService(processor, storage, repo, userRepo, emailer)
+		service, m := newTestService(DefaultConfig())
 
-		repo.On("GetByID", ctx, int64(1)).Return(nil, nil)
+		m.repo.On("Claim", ctx, int64(1), mock.Anything).Return(nil, nil)
+		m.repo.On("GetByID", ctx, int64(1)).Return(nil, nil)
 
 		err := service.ProcessVideoByID(ctx, 1)
 
@@ -30,121 +95,800 @@ func TestWorkerService_ProcessVideoByID(t *testing.T) {
 	})
 
 	t.Run("video already processed", func(t *testing.T) {
-		processor := new(MockVideoProcessor)
-		storage := new(MockStorage)
-		repo := new(MockVideoRepository)
-		userRepo := new(MockUserRepository)
-		emailer := new(MockEmailSender)
-		service := NewWorkerService(processor, storage, repo, userRepo, emailer)
+		service, m := newTestService(DefaultConfig())
 
 		video := &domain.Video{ID: 1, Status: domain.StatusCompleted}
-		repo.On("GetByID", ctx, int64(1)).Return(video, nil)
+		m.repo.On("Claim", ctx, int64(1), mock.Anything).Return(nil, nil)
+		m.repo.On("GetByID", ctx, int64(1)).Return(video, nil)
 
 		err := service.ProcessVideoByID(ctx, 1)
 
 		assert.NoError(t, err)
-		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		m.repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
 	})
 
 	t.Run("success processing", func(t *testing.T) {
-		processor := new(MockVideoProcessor)
-		storage := new(MockStorage)
-		repo := new(MockVideoRepository)
-		userRepo := new(MockUserRepository)
-		emailer := new(MockEmailSender)
-		service := NewWorkerService(processor, storage, repo, userRepo, emailer)
-
-		video := &domain.Video{ID: 1, Status: domain.StatusPending, Filename: "video.mp4"}
-		repo.On("GetByID", ctx, int64(1)).Return(video, nil)
-		repo.On("Update", ctx, mock.MatchedBy(func(v *domain.Video) bool {
+		service, m := newTestService(DefaultConfig())
+
+		video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.MatchedBy(func(v *domain.Video) bool {
 			return v.Status == domain.StatusProcessing
 		})).Return(nil)
+		m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", domain.DefaultExtractionOptions(), mock.Anything).Return(domain.ExtractionResult{Frames: []string{"/tmp/frame1.jpg", "/tmp/frame2.jpg"}, Mode: domain.ExtractionModeFixed}, nil)
+		m.expectArchived("/tmp/frame1.jpg", "/tmp/frame2.jpg")
+
+		m.repo.On("Update", ctx, mock.MatchedBy(func(v *domain.Video) bool {
+			return v.Status == domain.StatusCompleted && v.FrameCount == 2 && v.ZipPath == "frames_video.zip" &&
+				v.ExtractionMode == domain.ExtractionModeFixed && v.Metadata != nil && v.Metadata.Codec == "h264" &&
+				v.Attempts == 1 && v.ArchiveSize == sampleArchive().Size && v.ArchiveSHA256 == sampleArchive().SHA256
+		})).Return(nil)
 
-		storage.On("GetUploadPath", "video.mp4").Return("/uploads/video.mp4")
-		processor.On("ExtractFrames", "/uploads/video.mp4", "video").Return([]string{"/tmp/frame1.jpg", "/tmp/frame2.jpg"}, nil)
-		storage.On("SaveZip", "frames_video.zip", []string{"/tmp/frame1.jpg", "/tmp/frame2.jpg"}).Return(nil)
+		err := service.ProcessVideoByID(ctx, 1)
+
+		assert.NoError(t, err)
+		m.repo.AssertExpectations(t)
+		m.processor.AssertExpectations(t)
+		m.storage.AssertExpectations(t)
+	})
 
-		storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
-		storage.On("DeleteDir", "/tmp").Return(nil)
+	t.Run("streamed frames go straight into the archive", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.StreamFrames = true
+		service, m := newTestService(cfg)
+		archive := new(MockArchiveWriter)
 
-		repo.On("Update", ctx, mock.MatchedBy(func(v *domain.Video) bool {
-			return v.Status == domain.StatusCompleted && v.FrameCount == 2 && v.ZipPath == "frames_video.zip"
+		video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.MatchedBy(func(v *domain.Video) bool {
+			return v.Status == domain.StatusProcessing
+		})).Return(nil)
+		m.storage.On("CreateArchive", "frames_video.zip", domain.ArchiveFormatZip).Return(archive, nil)
+		m.processor.On("StreamFrames", mock.Anything, "/uploads/video.mp4", domain.DefaultExtractionOptions(), mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				sink := args.Get(4).(ports.FrameSink)
+				sink("frame_0001.png", []byte("png"))
+			}).
+			Return(domain.ExtractionResult{FrameCount: 1, Mode: domain.ExtractionModeFixed}, nil)
+		archive.On("AddFile", "frame_0001.png", []byte("png")).Return(nil)
+		archive.On("AddFile", domain.ManifestFilename, mock.Anything).Return(nil)
+		archive.On("Close").Return(sampleArchive(), nil)
+		m.storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
+
+		m.repo.On("Update", ctx, mock.MatchedBy(func(v *domain.Video) bool {
+			return v.Status == domain.StatusCompleted && v.FrameCount == 1 && v.ZipPath == "frames_video.zip"
 		})).Return(nil)
 
 		err := service.ProcessVideoByID(ctx, 1)
 
 		assert.NoError(t, err)
-		repo.AssertExpectations(t)
-		processor.AssertExpectations(t)
-		storage.AssertExpectations(t)
+		archive.AssertExpectations(t)
+		m.storage.AssertNotCalled(t, "SaveArchive", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		m.storage.AssertNotCalled(t, "DeleteDir", mock.Anything)
+		m.processor.AssertNotCalled(t, "ExtractFrames", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("failed stream aborts the archive", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.StreamFrames = true
+		service, m := newTestService(cfg)
+		archive := new(MockArchiveWriter)
+
+		video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.storage.On("CreateArchive", "frames_video.zip", domain.ArchiveFormatZip).Return(archive, nil)
+		m.processor.On("StreamFrames", mock.Anything, "/uploads/video.mp4", domain.DefaultExtractionOptions(), mock.Anything, mock.Anything).
+			Return(domain.ExtractionResult{}, errors.New("broken pipe"))
+		archive.On("Abort").Return(nil)
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		var retry *domain.RetryError
+		assert.ErrorAs(t, err, &retry)
+		assert.Equal(t, domain.StatusPending, video.Status)
+		archive.AssertExpectations(t)
+		archive.AssertNotCalled(t, "Close")
 	})
 
 	t.Run("extraction failure", func(t *testing.T) {
-		processor := new(MockVideoProcessor)
-		storage := new(MockStorage)
-		repo := new(MockVideoRepository)
-		userRepo := new(MockUserRepository)
-		emailer := new(MockEmailSender)
-		service := NewWorkerService(processor, storage, repo, userRepo, emailer)
-
-		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusPending, Filename: "video.mp4"}
-		user := &domain.User{ID: 10, Name: "Test User", Email: "test@example.com"}
+		service, m := newTestService(DefaultConfig())
 
-		repo.On("GetByID", ctx, int64(1)).Return(video, nil)
-		repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
+		user := &domain.User{ID: 10, Name: "Test User", Email: "test@example.com"}
 
-		storage.On("GetUploadPath", "video.mp4").Return("/uploads/video.mp4")
-		processor.On("ExtractFrames", "/uploads/video.mp4", "video").Return([]string{}, errors.New("ffmpeg error"))
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", domain.DefaultExtractionOptions(), mock.Anything).Return(domain.ExtractionResult{}, fmt.Errorf("%w: ffmpeg error", domain.ErrInvalidVideo))
 
-		repo.On("Update", ctx, mock.MatchedBy(func(v *domain.Video) bool {
+		m.repo.On("Update", ctx, mock.MatchedBy(func(v *domain.Video) bool {
 			return v.Status == domain.StatusFailed && assert.Contains(t, v.Message, "ffmpeg error")
 		})).Return(nil)
-		storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
+		m.storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
 
 		// Notification expectations
-		userRepo.On("GetByID", int64(10)).Return(user, nil)
-		emailer.On("SendEmail", "test@example.com", mock.Anything, mock.Anything).Return(nil)
+		m.users.On("GetByID", int64(10)).Return(user, nil)
+		m.emailer.On("SendEmail", "test@example.com", mock.Anything, mock.Anything).Return(nil)
 
 		err := service.ProcessVideoByID(ctx, 1)
 
-		assert.Error(t, err)
-		assert.Equal(t, "ffmpeg error", err.Error())
-		userRepo.AssertExpectations(t)
-		emailer.AssertExpectations(t)
+		assert.ErrorIs(t, err, domain.ErrInvalidVideo)
+		assert.Contains(t, video.LastError, "ffmpeg error")
+		m.users.AssertExpectations(t)
+		m.emailer.AssertExpectations(t)
 	})
 
-	t.Run("zipping failure", func(t *testing.T) {
-		processor := new(MockVideoProcessor)
-		storage := new(MockStorage)
-		repo := new(MockVideoRepository)
-		userRepo := new(MockUserRepository)
-		emailer := new(MockEmailSender)
-		service := NewWorkerService(processor, storage, repo, userRepo, emailer)
+	t.Run("invalid video is rejected before extraction", func(t *testing.T) {
+		service, m := newTestService(DefaultConfig())
 
-		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusPending, Filename: "video.mp4"}
+		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
 		user := &domain.User{ID: 10, Name: "Test User", Email: "test@example.com"}
 
-		repo.On("GetByID", ctx, int64(1)).Return(video, nil)
-		repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.expectUpload(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.probe.On("Probe", ctx, "/uploads/video.mp4").Return(nil, fmt.Errorf("%w: empty file", domain.ErrInvalidVideo))
+		m.storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
+
+		m.users.On("GetByID", int64(10)).Return(user, nil)
+		m.emailer.On("SendEmail", "test@example.com", mock.Anything, mock.Anything).Return(nil)
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidVideo)
+		assert.Equal(t, domain.StatusFailed, video.Status)
+		assert.Contains(t, video.Message, "empty file")
+		m.processor.AssertNotCalled(t, "ExtractFrames", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		m.emailer.AssertExpectations(t)
+	})
+
+	t.Run("video without video stream is rejected", func(t *testing.T) {
+		service, m := newTestService(DefaultConfig())
 
-		storage.On("GetUploadPath", "video.mp4").Return("/uploads/video.mp4")
-		processor.On("ExtractFrames", "/uploads/video.mp4", "video").Return([]string{"/tmp/f1.jpg"}, nil)
-		storage.On("SaveZip", "frames_video.zip", []string{"/tmp/f1.jpg"}).Return(errors.New("zip error"))
+		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
 
-		repo.On("Update", ctx, mock.MatchedBy(func(v *domain.Video) bool {
+		m.expectUpload(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.probe.On("Probe", ctx, "/uploads/video.mp4").Return(&domain.VideoMetadata{Container: "mp3", Duration: 30, Size: 2048}, nil)
+		m.storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
+
+		m.users.On("GetByID", int64(10)).Return(nil, nil)
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidVideo)
+		assert.Equal(t, domain.StatusFailed, video.Status)
+		assert.Contains(t, video.Message, "no video stream")
+		m.processor.AssertNotCalled(t, "ExtractFrames", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("zipping failure on last attempt", func(t *testing.T) {
+		service, m := newTestService(DefaultConfig())
+
+		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusProcessing, Attempts: 3, Filename: "video.mp4"}
+		user := &domain.User{ID: 10, Name: "Test User", Email: "test@example.com"}
+
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", domain.DefaultExtractionOptions(), mock.Anything).Return(domain.ExtractionResult{Frames: []string{"/tmp/f1.jpg"}, Mode: domain.ExtractionModeFixed}, nil)
+		m.storage.On("SaveArchive", "frames_video.zip", domain.ArchiveFormatZip, []string{"/tmp/f1.jpg"}, mock.Anything).Return(domain.ArchiveInfo{}, errors.New("zip error"))
+
+		m.repo.On("Update", ctx, mock.MatchedBy(func(v *domain.Video) bool {
 			return v.Status == domain.StatusFailed && assert.Contains(t, v.Message, "zip error")
 		})).Return(nil)
-		storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
+		m.storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
 
 		// Notification expectations
-		userRepo.On("GetByID", int64(10)).Return(user, nil)
-		emailer.On("SendEmail", "test@example.com", mock.Anything, mock.Anything).Return(nil)
+		m.users.On("GetByID", int64(10)).Return(user, nil)
+		m.emailer.On("SendEmail", "test@example.com", mock.Anything, mock.Anything).Return(nil)
 
 		err := service.ProcessVideoByID(ctx, 1)
 
 		assert.Error(t, err)
 		assert.Equal(t, "zip error", err.Error())
-		userRepo.AssertExpectations(t)
-		emailer.AssertExpectations(t)
+		m.users.AssertExpectations(t)
+		m.emailer.AssertExpectations(t)
+	})
+
+	t.Run("transient zipping failure is retried", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.RetryBaseDelay = 10 * time.Second
+		cfg.RetryMaxDelay = time.Minute
+		service, m := newTestService(cfg)
+
+		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusProcessing, Attempts: 2, Filename: "video.mp4"}
+
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", domain.DefaultExtractionOptions(), mock.Anything).Return(domain.ExtractionResult{Frames: []string{"/tmp/f1.jpg"}, Mode: domain.ExtractionModeFixed}, nil)
+		m.storage.On("SaveArchive", "frames_video.zip", domain.ArchiveFormatZip, []string{"/tmp/f1.jpg"}, mock.Anything).Return(domain.ArchiveInfo{}, errors.New("disk full"))
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		var retry *domain.RetryError
+		assert.ErrorAs(t, err, &retry)
+		// Second attempt: 20s with up to half of it randomized
+		assert.GreaterOrEqual(t, retry.Delay, 10*time.Second)
+		assert.LessOrEqual(t, retry.Delay, 20*time.Second)
+		assert.Equal(t, domain.StatusPending, video.Status)
+		assert.Equal(t, "disk full", video.LastError)
+		assert.NotNil(t, video.NextAttemptAt)
+		m.storage.AssertNotCalled(t, "DeleteFile", "/uploads/video.mp4")
+		m.users.AssertNotCalled(t, "GetByID", mock.Anything)
+	})
+
+	t.Run("missing upload fails without probing", func(t *testing.T) {
+		service, m := newTestService(DefaultConfig())
+
+		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
+
+		m.repo.On("Claim", ctx, int64(1), mock.Anything).Return(video, nil)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.users.On("GetByID", int64(10)).Return(nil, errors.New("not found"))
+
+		m.storage.On("GetUploadPath", "video.mp4").Return("s3://videos/uploads/video.mp4")
+		m.storage.On("FetchUpload", mock.Anything, "video.mp4").Return("", fmt.Errorf("%w: upload video.mp4 not found", domain.ErrInvalidVideo))
+		m.storage.On("DeleteFile", "s3://videos/uploads/video.mp4").Return(nil)
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidVideo)
+		assert.Equal(t, domain.StatusFailed, video.Status)
+		m.probe.AssertNotCalled(t, "Probe", mock.Anything, mock.Anything)
+		m.storage.AssertExpectations(t)
+	})
+
+	t.Run("redelivery before backoff is over", func(t *testing.T) {
+		service, m := newTestService(DefaultConfig())
+
+		next := time.Now().Add(time.Minute)
+		video := &domain.Video{ID: 1, Status: domain.StatusPending, Attempts: 1, NextAttemptAt: &next}
+		m.repo.On("Claim", ctx, int64(1), mock.Anything).Return(nil, nil)
+		m.repo.On("GetByID", ctx, int64(1)).Return(video, nil)
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		var retry *domain.RetryError
+		assert.ErrorAs(t, err, &retry)
+		assert.InDelta(t, time.Minute.Seconds(), retry.Delay.Seconds(), 1)
+		m.probe.AssertNotCalled(t, "Probe", mock.Anything, mock.Anything)
+	})
+
+	t.Run("redelivery while a dead worker holds the video", func(t *testing.T) {
+		cfg := DefaultConfig()
+		service, m := newTestService(cfg)
+
+		beat := time.Now().Add(-3 * cfg.HeartbeatInterval)
+		video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, WorkerID: "worker-b",
+			HeartbeatAt: &beat, UpdatedAt: beat}
+		m.repo.On("Claim", ctx, int64(1), mock.Anything).Return(nil, nil)
+		m.repo.On("GetByID", ctx, int64(1)).Return(video, nil)
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		var retry *domain.RetryError
+		require.ErrorAs(t, err, &retry)
+		assert.Equal(t, cfg.StaleAfter, retry.Delay)
+	})
+
+	t.Run("redelivery while a live worker holds the video", func(t *testing.T) {
+		service, m := newTestService(DefaultConfig())
+
+		beat := time.Now()
+		video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, WorkerID: "worker-b",
+			HeartbeatAt: &beat, UpdatedAt: beat.Add(-time.Hour)}
+		m.repo.On("Claim", ctx, int64(1), mock.Anything).Return(nil, nil)
+		m.repo.On("GetByID", ctx, int64(1)).Return(video, nil)
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("video taken over by another worker is left alone", func(t *testing.T) {
+		service, m := newTestService(DefaultConfig())
+
+		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4", WorkerID: "worker-a"}
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(domain.ErrLostOwnership)
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		assert.NoError(t, err)
+		m.repo.AssertNumberOfCalls(t, "Update", 1)
+		m.processor.AssertNotCalled(t, "ExtractFrames", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		m.storage.AssertNotCalled(t, "DeleteFile", mock.Anything)
+		m.users.AssertNotCalled(t, "GetByID", mock.Anything)
+	})
+}
+
+func TestWorkerService_ProcessVideoWithOptions(t *testing.T) {
+	ctx := context.Background()
+
+	run := func(t *testing.T, video *domain.Video, opts *domain.ExtractionOptions, expected domain.ExtractionOptions, mode string) {
+		service, m := newTestService(DefaultConfig())
+
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", expected, mock.Anything).Return(domain.ExtractionResult{Frames: []string{"/tmp/f1.png"}, Mode: mode}, nil)
+		m.expectArchived("/tmp/f1.png")
+
+		err := service.ProcessVideoWithOptions(ctx, 1, opts)
+
+		assert.NoError(t, err)
+		m.processor.AssertExpectations(t)
+		assert.Equal(t, expected, video.Options)
+		assert.Equal(t, mode, video.ExtractionMode)
+	}
+
+	t.Run("row options are used", func(t *testing.T) {
+		video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4",
+			Options: domain.ExtractionOptions{Interval: 5, StartTime: 10, EndTime: 70, MaxFrames: 12}}
+
+		run(t, video, nil, domain.ExtractionOptions{Mode: domain.ExtractionModeFixed, Interval: 5, StartTime: 10, EndTime: 70, MaxFrames: 12,
+			Format: domain.ImageFormatPNG},
+			domain.ExtractionModeFixed)
+	})
+
+	t.Run("event options take precedence", func(t *testing.T) {
+		video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4",
+			Options: domain.ExtractionOptions{FPS: 2}}
+
+		run(t, video, &domain.ExtractionOptions{FPS: 0.5, MaxFrames: 3},
+			domain.ExtractionOptions{Mode: domain.ExtractionModeFixed, FPS: 0.5, MaxFrames: 3, Format: domain.ImageFormatPNG}, domain.ExtractionModeFixed)
+	})
+
+	t.Run("invalid options fall back to defaults", func(t *testing.T) {
+		video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4",
+			Options: domain.ExtractionOptions{FPS: -3, StartTime: 50, EndTime: 20, MaxFrames: -1}}
+
+		run(t, video, nil, domain.DefaultExtractionOptions(), domain.ExtractionModeFixed)
+	})
+
+	t.Run("lossy format gets default quality", func(t *testing.T) {
+		video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4",
+			Options: domain.ExtractionOptions{Format: "JPG", Quality: 300, MaxWidth: 1280, MaxHeight: -1}}
+
+		run(t, video, nil, domain.ExtractionOptions{Mode: domain.ExtractionModeFixed, FPS: domain.DefaultFPS,
+			Format: domain.ImageFormatJPEG, Quality: domain.DefaultImageQuality, MaxWidth: 1280}, domain.ExtractionModeFixed)
+	})
+
+	t.Run("scene mode defaults and records producing mode", func(t *testing.T) {
+		video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4",
+			Options: domain.ExtractionOptions{Mode: domain.ExtractionModeScene, SceneThreshold: 7, MinFrames: 20, MaxFrames: 10}}
+
+		run(t, video, nil, domain.ExtractionOptions{Mode: domain.ExtractionModeScene, FPS: domain.DefaultFPS,
+			SceneThreshold: domain.DefaultSceneThreshold, MinFrames: 10, MaxFrames: 10, Format: domain.ImageFormatPNG}, domain.ExtractionModeScene)
+	})
+
+	t.Run("scene mode fallback is recorded", func(t *testing.T) {
+		video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4",
+			Options: domain.ExtractionOptions{Mode: domain.ExtractionModeScene, SceneThreshold: 0.5, MinFrames: 5}}
+
+		run(t, video, nil, domain.ExtractionOptions{Mode: domain.ExtractionModeScene, FPS: domain.DefaultFPS,
+			SceneThreshold: 0.5, MinFrames: 5, Format: domain.ImageFormatPNG}, domain.ExtractionModeFixed)
+	})
+}
+
+func TestWorkerService_ArchiveFormat(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		configured string
+		requested  string
+		format     string
+		archive    string
+	}{
+		{"defaults to zip", "", "", domain.ArchiveFormatZip, "frames_video.zip"},
+		{"configured format", "tar.gz", "", domain.ArchiveFormatTarGz, "frames_video.tar.gz"},
+		{"job format takes precedence", "tar", "TZST", domain.ArchiveFormatTarZst, "frames_video.tar.zst"},
+		{"unknown job format falls back to configured", "tar", "rar", domain.ArchiveFormatTar, "frames_video.tar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.ArchiveFormat = tt.configured
+			service, m := newTestService(cfg)
+
+			video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4",
+				Options: domain.ExtractionOptions{ArchiveFormat: tt.requested}}
+			m.expectProbed(ctx, video)
+			m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+			m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", mock.Anything, mock.Anything).Return(domain.ExtractionResult{Frames: []string{"/tmp/f1.png"}, Mode: domain.ExtractionModeFixed}, nil)
+			m.storage.On("SaveArchive", tt.archive, tt.format, []string{"/tmp/f1.png"}, mock.Anything).Return(sampleArchive(), nil)
+			m.storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
+			m.storage.On("DeleteDir", "/tmp").Return(nil)
+
+			err := service.ProcessVideoByID(ctx, 1)
+
+			assert.NoError(t, err)
+			m.storage.AssertExpectations(t)
+			assert.Equal(t, tt.archive, video.ZipPath)
+			assert.Equal(t, tt.format, video.ArchiveFormat)
+			assert.Equal(t, domain.ArchiveExtension(tt.format), video.ArchiveExt)
+		})
+	}
+}
+
+func TestWorkerService_Manifest(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.ManifestCSV = true
+	service, m := newTestService(cfg)
+
+	video := &domain.Video{ID: 1, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4",
+		Options: domain.ExtractionOptions{FPS: 2, StartTime: 10}}
+	frames := []domain.FrameInfo{
+		{Index: 1, Filename: "frame_0001.png", Timestamp: 10, Width: 1920, Height: 1080, Size: 3, SHA256: "abc"},
+		{Index: 2, Filename: "frame_0002.png", Timestamp: 10.5, Width: 1920, Height: 1080, Size: 4, SHA256: "def"},
+	}
+	m.expectProbed(ctx, video)
+	m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+	m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", mock.Anything, mock.Anything).
+		Return(domain.ExtractionResult{Frames: []string{"/tmp/frame_0001.png", "/tmp/frame_0002.png"}, FrameInfo: frames, Mode: domain.ExtractionModeFixed}, nil)
+	var extra []ports.ArchiveEntry
+	m.storage.On("SaveArchive", "frames_video.zip", domain.ArchiveFormatZip, []string{"/tmp/frame_0001.png", "/tmp/frame_0002.png"}, mock.Anything).
+		Run(func(args mock.Arguments) {
+			extra = args.Get(3).([]ports.ArchiveEntry)
+		}).
+		Return(sampleArchive(), nil)
+	m.storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
+	m.storage.On("DeleteDir", "/tmp").Return(nil)
+
+	err := service.ProcessVideoByID(ctx, 1)
+
+	assert.NoError(t, err)
+	require.Len(t, extra, 2)
+	assert.Equal(t, domain.ManifestFilename, extra[0].Name)
+	assert.Equal(t, domain.ManifestCSVFilename, extra[1].Name)
+
+	var manifest domain.FrameManifest
+	require.NoError(t, json.Unmarshal(extra[0].Data, &manifest))
+	assert.Equal(t, domain.ManifestVersion, manifest.Version)
+	assert.Equal(t, int64(1), manifest.VideoID)
+	assert.Equal(t, "video.mp4", manifest.Source.Filename)
+	assert.Equal(t, "h264", manifest.Source.Metadata.Codec)
+	assert.Equal(t, 2.0, manifest.Extraction.Options.FPS)
+	assert.Equal(t, domain.ImageFormatPNG, manifest.Extraction.Options.Format)
+	assert.Equal(t, domain.ArchiveFormatZip, manifest.Extraction.ArchiveFormat)
+	assert.Equal(t, 2, manifest.FrameCount)
+	assert.Equal(t, frames, manifest.Frames)
+
+	assert.Equal(t, "index,filename,timestamp,width,height,size,sha256\n"+
+		"1,frame_0001.png,10.000000,1920,1080,3,abc\n"+
+		"2,frame_0002.png,10.500000,1920,1080,4,def\n", string(extra[1].Data))
+}
+
+func TestWorkerService_Timeouts(t *testing.T) {
+	t.Run("job timeout scales with selected duration", func(t *testing.T) {
+		service, _ := newTestService(Config{
+			JobTimeoutBase:   time.Minute,
+			JobTimeoutFactor: 2,
+			JobTimeoutMax:    time.Hour,
+		})
+		metadata := &domain.VideoMetadata{Duration: 600}
+
+		assert.Equal(t, time.Minute, service.jobTimeout(&domain.Video{}))
+		assert.Equal(t, 21*time.Minute, service.jobTimeout(&domain.Video{Metadata: metadata}))
+		assert.Equal(t, 3*time.Minute, service.jobTimeout(&domain.Video{Metadata: metadata,
+			Options: domain.ExtractionOptions{StartTime: 30, EndTime: 90}}))
+		assert.Equal(t, 11*time.Minute, service.jobTimeout(&domain.Video{Metadata: metadata,
+			Options: domain.ExtractionOptions{StartTime: 300, EndTime: 9000}}))
+		assert.Equal(t, time.Hour, service.jobTimeout(&domain.Video{Metadata: &domain.VideoMetadata{Duration: 36000}}))
+	})
+
+	t.Run("timed out extraction fails the video", func(t *testing.T) {
+		ctx := context.Background()
+		service, m := newTestService(DefaultConfig())
+
+		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
+
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.processor.On("ExtractFrames", mock.MatchedBy(func(c context.Context) bool {
+			_, ok := c.Deadline()
+			return ok
+		}), "/uploads/video.mp4", "video", domain.DefaultExtractionOptions(), mock.Anything).
+			Return(domain.ExtractionResult{}, fmt.Errorf("ffmpeg interrupted: %w", context.DeadlineExceeded))
+		m.storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
+		m.users.On("GetByID", int64(10)).Return(nil, nil)
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		assert.ErrorIs(t, err, domain.ErrProcessingTimeout)
+		assert.Equal(t, domain.StatusFailed, video.Status)
+		assert.Contains(t, video.Message, "Tempo limite")
+		m.processor.AssertExpectations(t)
+	})
+
+	t.Run("cancelled extraction requeues the video", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		service, m := newTestService(DefaultConfig())
+
+		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
+
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", mock.Anything, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", domain.DefaultExtractionOptions(), mock.Anything).
+			Run(func(mock.Arguments) { cancel() }).
+			Return(domain.ExtractionResult{}, fmt.Errorf("ffmpeg interrupted: %w", context.Canceled))
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		var retry *domain.RetryError
+		assert.ErrorAs(t, err, &retry)
+		assert.Zero(t, retry.Delay)
+		assert.Equal(t, domain.StatusPending, video.Status)
+		assert.Zero(t, video.Attempts)
+		assert.Contains(t, video.Message, "interrompido")
+		m.storage.AssertNotCalled(t, "DeleteFile", mock.Anything)
+		m.emailer.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestWorkerService_Progress(t *testing.T) {
+	ctx := context.Background()
+
+	run := func(t *testing.T, interval time.Duration, updates []domain.Progress) *MockVideoRepository {
+		cfg := DefaultConfig()
+		cfg.ProgressInterval = interval
+		service, m := newTestService(cfg)
+
+		video := &domain.Video{ID: 7, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
+
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.repo.On("UpdateProgress", ctx, int64(7), cfg.WorkerID, mock.AnythingOfType("domain.Progress")).Return(nil)
+		m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", domain.DefaultExtractionOptions(), mock.Anything).
+			Run(func(args mock.Arguments) {
+				onProgress := args.Get(4).(ports.ProgressFunc)
+				for _, p := range updates {
+					onProgress(p)
+				}
+			}).
+			Return(domain.ExtractionResult{Frames: []string{"/tmp/f1.png", "/tmp/f2.png"}, Mode: domain.ExtractionModeFixed}, nil)
+		m.expectArchived("/tmp/f1.png", "/tmp/f2.png")
+
+		err := service.ProcessVideoByID(ctx, 7)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.Progress{Percent: 100, Frames: 2}, video.Progress)
+		return m.repo
+	}
+
+	updates := []domain.Progress{
+		{Percent: 10, Frames: 1, ETASeconds: 9},
+		{Percent: 50, Frames: 5, ETASeconds: 5},
+		{Percent: 90, Frames: 9, ETASeconds: 1},
+	}
+
+	t.Run("updates are throttled", func(t *testing.T) {
+		repo := run(t, time.Hour, updates)
+
+		repo.AssertNumberOfCalls(t, "UpdateProgress", 1)
+		repo.AssertCalled(t, "UpdateProgress", ctx, int64(7), DefaultConfig().WorkerID, updates[0])
+	})
+
+	t.Run("every update is written without throttling", func(t *testing.T) {
+		repo := run(t, 0, updates)
+
+		repo.AssertNumberOfCalls(t, "UpdateProgress", 3)
+		repo.AssertCalled(t, "UpdateProgress", ctx, int64(7), DefaultConfig().WorkerID, updates[2])
+	})
+
+	t.Run("extraction stops when the video was taken over", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.ProgressInterval = 0
+		service, m := newTestService(cfg)
+
+		video := &domain.Video{ID: 7, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
+
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil).Once()
+		m.repo.On("UpdateProgress", ctx, int64(7), cfg.WorkerID, mock.AnythingOfType("domain.Progress")).Return(domain.ErrLostOwnership)
+		m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", domain.DefaultExtractionOptions(), mock.Anything).
+			Run(func(args mock.Arguments) {
+				args.Get(4).(ports.ProgressFunc)(updates[0])
+				<-args.Get(0).(context.Context).Done()
+			}).
+			Return(domain.ExtractionResult{}, fmt.Errorf("ffmpeg interrupted: %w", context.Canceled))
+
+		err := service.ProcessVideoByID(ctx, 7)
+
+		assert.NoError(t, err)
+		m.repo.AssertNumberOfCalls(t, "Update", 1)
+		m.storage.AssertNotCalled(t, "DeleteFile", mock.Anything)
+	})
+}
+
+func TestWorkerService_ReapStaleVideos(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+
+	t.Run("requeues or fails stale videos by attempts", func(t *testing.T) {
+		service, m := newTestService(cfg)
+
+		m.repo.On("GetStale", ctx, cfg.StaleAfter).Return([]domain.Video{
+			{ID: 1, UserID: 10, Filename: "a.mp4", Status: domain.StatusProcessing, Attempts: 1},
+			{ID: 2, UserID: 10, Filename: "b.mp4", Status: domain.StatusProcessing, Attempts: cfg.MaxAttempts},
+		}, nil)
+		m.repo.On("ReleaseStale", ctx, int64(1), domain.StatusPending, mock.Anything, cfg.StaleAfter).Return(true, nil)
+		m.repo.On("ReleaseStale", ctx, int64(2), domain.StatusFailed, mock.Anything, cfg.StaleAfter).Return(true, nil)
+		m.storage.On("GetUploadPath", "b.mp4").Return("/uploads/b.mp4")
+		m.storage.On("DeleteFile", "/uploads/b.mp4").Return(nil)
+		m.users.On("GetByID", int64(10)).Return(&domain.User{ID: 10, Name: "Test User", Email: "test@example.com"}, nil)
+		m.emailer.On("SendEmail", "test@example.com", mock.Anything, mock.Anything).Return(nil)
+
+		reaped, err := service.ReapStaleVideos(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, reaped)
+		m.repo.AssertExpectations(t)
+		m.storage.AssertExpectations(t)
+		m.emailer.AssertNumberOfCalls(t, "SendEmail", 1)
+	})
+
+	t.Run("skips videos that came back to life", func(t *testing.T) {
+		service, m := newTestService(cfg)
+
+		m.repo.On("GetStale", ctx, cfg.StaleAfter).Return([]domain.Video{
+			{ID: 1, Filename: "a.mp4", Status: domain.StatusProcessing, Attempts: cfg.MaxAttempts},
+		}, nil)
+		m.repo.On("ReleaseStale", ctx, int64(1), domain.StatusFailed, mock.Anything, cfg.StaleAfter).Return(false, nil)
+
+		reaped, err := service.ReapStaleVideos(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, reaped)
+		m.storage.AssertNotCalled(t, "DeleteFile", mock.Anything)
+	})
+}
+
+func TestWorkerService_Heartbeat(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.HeartbeatInterval = 5 * time.Millisecond
+	service, m := newTestService(cfg)
+
+	beats := make(chan struct{}, 10)
+	m.repo.On("Heartbeat", mock.Anything, int64(1), cfg.WorkerID).Run(func(mock.Arguments) {
+		select {
+		case beats <- struct{}{}:
+		default:
+		}
+	}).Return(nil)
+
+	stop := service.startHeartbeat(ctx, 1, func(error) { t.Error("ownership was not lost") })
+	<-beats
+	<-beats
+	stop()
+
+	calls := len(m.repo.Calls)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, calls, len(m.repo.Calls), "no heartbeat after stop")
+}
+
+func TestWorkerService_HeartbeatLostOwnership(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.HeartbeatInterval = 5 * time.Millisecond
+	service, m := newTestService(cfg)
+
+	m.repo.On("Heartbeat", mock.Anything, int64(1), cfg.WorkerID).Return(domain.ErrLostOwnership)
+
+	owned, lose := context.WithCancelCause(context.Background())
+	stop := service.startHeartbeat(context.Background(), 1, lose)
+	defer stop()
+
+	<-owned.Done()
+	assert.ErrorIs(t, context.Cause(owned), domain.ErrLostOwnership)
+	time.Sleep(20 * time.Millisecond)
+	m.repo.AssertNumberOfCalls(t, "Heartbeat", 1)
+}
+
+func TestWorkerService_ProcessNextPending(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.WorkerID = "worker-a"
+
+	t.Run("nothing pending", func(t *testing.T) {
+		service, m := newTestService(cfg)
+
+		m.repo.On("ClaimNextPending", ctx, "worker-a").Return(nil, nil)
+
+		found, err := service.ProcessNextPending(ctx)
+
+		assert.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("claimed video is processed", func(t *testing.T) {
+		service, m := newTestService(cfg)
+
+		video := &domain.Video{ID: 3, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4", WorkerID: "worker-a"}
+		m.repo.On("ClaimNextPending", ctx, "worker-a").Return(video, nil)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.storage.On("GetUploadPath", "video.mp4").Return("/uploads/video.mp4")
+		m.storage.On("FetchUpload", mock.Anything, "video.mp4").Return("/uploads/video.mp4", nil)
+		m.probe.On("Probe", ctx, "/uploads/video.mp4").Return(sampleMetadata(), nil)
+		m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", domain.DefaultExtractionOptions(), mock.Anything).
+			Return(domain.ExtractionResult{Frames: []string{"/tmp/f1.png"}, Mode: domain.ExtractionModeFixed}, nil)
+		m.expectArchived("/tmp/f1.png")
+
+		found, err := service.ProcessNextPending(ctx)
+
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, domain.StatusCompleted, video.Status)
+	})
+}
+
+func TestWorkerService_Backoff(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RetryBaseDelay = 10 * time.Second
+	cfg.RetryMaxDelay = time.Minute
+	service, _ := newTestService(cfg)
+
+	for attempts, ceiling := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second, 4: time.Minute, 10: time.Minute} {
+		delay := service.backoff(attempts)
+		assert.GreaterOrEqual(t, delay, ceiling/2, "attempt %d", attempts)
+		assert.LessOrEqual(t, delay, ceiling, "attempt %d", attempts)
+	}
+}
+
+func TestWorkerService_Events(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("completed video records started and completed in the outbox", func(t *testing.T) {
+		service, m := newTestService(DefaultConfig())
+		outbox := new(MockOutboxRepository)
+		service.outbox = outbox
+
+		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusProcessing, Attempts: 2, Filename: "video.mp4"}
+		m.expectProbed(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.processor.On("ExtractFrames", mock.Anything, "/uploads/video.mp4", "video", domain.DefaultExtractionOptions(), mock.Anything).Return(domain.ExtractionResult{Frames: []string{"/tmp/f1.png"}, Mode: domain.ExtractionModeFixed}, nil)
+		m.expectArchived("/tmp/f1.png")
+
+		outbox.On("Add", ctx, mock.MatchedBy(func(e domain.VideoEvent) bool {
+			return e.Type == domain.EventProcessingStarted && e.Status == domain.StatusProcessing &&
+				e.VideoID == 1 && e.UserID == 10 && e.ID() == "video-1.video.processing.started.2"
+		})).Return(nil).Once()
+		outbox.On("Add", ctx, mock.MatchedBy(func(e domain.VideoEvent) bool {
+			return e.Type == domain.EventProcessingCompleted && e.Version == domain.EventSchemaVersion &&
+				e.ZipPath == "frames_video.zip" && e.FrameCount == 1 && e.Error == ""
+		})).Return(nil).Once()
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		assert.NoError(t, err)
+		outbox.AssertExpectations(t)
+	})
+
+	t.Run("failed video records the error in the outbox", func(t *testing.T) {
+		service, m := newTestService(DefaultConfig())
+		outbox := new(MockOutboxRepository)
+		service.outbox = outbox
+
+		video := &domain.Video{ID: 1, UserID: 10, Status: domain.StatusProcessing, Attempts: 1, Filename: "video.mp4"}
+		m.expectUpload(ctx, video)
+		m.repo.On("Update", ctx, mock.AnythingOfType("*domain.Video")).Return(nil)
+		m.probe.On("Probe", ctx, "/uploads/video.mp4").Return(nil, fmt.Errorf("%w: empty file", domain.ErrInvalidVideo))
+		m.storage.On("DeleteFile", "/uploads/video.mp4").Return(nil)
+		m.users.On("GetByID", int64(10)).Return(nil, nil)
+
+		outbox.On("Add", ctx, mock.MatchedBy(func(e domain.VideoEvent) bool {
+			return e.Type == domain.EventProcessingStarted
+		})).Return(nil).Once()
+		outbox.On("Add", ctx, mock.MatchedBy(func(e domain.VideoEvent) bool {
+			return e.Type == domain.EventProcessingFailed && e.Status == domain.StatusFailed &&
+				assert.Contains(t, e.Error, "empty file")
+		})).Return(nil).Once()
+
+		err := service.ProcessVideoByID(ctx, 1)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidVideo)
+		outbox.AssertExpectations(t)
 	})
 }
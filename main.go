@@ -2,19 +2,27 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	inbound_messaging "video-processor-worker/internal/adapters/inbound/messaging"
+	inbound_pgnotify "video-processor-worker/internal/adapters/inbound/pgnotify"
+	inbound_polling "video-processor-worker/internal/adapters/inbound/polling"
 	outbound_email "video-processor-worker/internal/adapters/outbound/email"
+	outbound_messaging "video-processor-worker/internal/adapters/outbound/messaging"
 	outbound_processor "video-processor-worker/internal/adapters/outbound/processor"
 	outbound_repository "video-processor-worker/internal/adapters/outbound/repository"
 	outbound_storage "video-processor-worker/internal/adapters/outbound/storage"
+	"video-processor-worker/internal/core/domain"
+	"video-processor-worker/internal/core/ports"
 	core_services "video-processor-worker/internal/core/services"
 
 	"net/http"
@@ -23,6 +31,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// pollerLockKey is the Postgres advisory lock key of the poller leader
+const pollerLockKey = 0x66696170785f706f // "fiapx_po"
+
 func main() {
 	fmt.Println("🚀 Video Processor Worker starting...")
 
@@ -43,6 +54,9 @@ func main() {
 	if _, err := exec.LookPath("ffmpeg"); err != nil {
 		log.Fatal("❌ Error: ffmpeg not found in system")
 	}
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		log.Fatal("❌ Error: ffprobe not found in system")
+	}
 
 	// Database initialization
 	dbPool, err := initDatabase(ctx)
@@ -52,33 +66,190 @@ func main() {
 	defer dbPool.Close()
 
 	// Initialize Adapters
-	storage := outbound_storage.NewFSStorage()
+	var storage ports.Storage
+	switch backend := getEnv("STORAGE_BACKEND", "fs"); backend {
+	case "fs":
+		storage = outbound_storage.NewFSStorage()
+	case "s3":
+		s3Defaults := outbound_storage.DefaultS3Config()
+		s3Cfg := outbound_storage.S3Config{
+			Endpoint:     getEnv("S3_ENDPOINT", s3Defaults.Endpoint),
+			Region:       getEnv("S3_REGION", s3Defaults.Region),
+			AccessKey:    getEnv("S3_ACCESS_KEY", s3Defaults.AccessKey),
+			SecretKey:    getEnv("S3_SECRET_KEY", s3Defaults.SecretKey),
+			UseSSL:       getEnvBool("S3_USE_SSL", s3Defaults.UseSSL),
+			Bucket:       getEnv("S3_BUCKET", s3Defaults.Bucket),
+			UploadPrefix: getEnv("S3_UPLOAD_PREFIX", s3Defaults.UploadPrefix),
+			OutputPrefix: getEnv("S3_OUTPUT_PREFIX", s3Defaults.OutputPrefix),
+			ScratchDir:   getEnv("S3_SCRATCH_DIR", s3Defaults.ScratchDir),
+		}
+		storage, err = outbound_storage.NewS3Storage(ctx, s3Cfg)
+		if err != nil {
+			log.Fatal("❌ Error initializing S3 storage: ", err)
+		}
+	default:
+		log.Fatalf("❌ Unknown STORAGE_BACKEND %q, expected fs or s3", backend)
+	}
 	processor := outbound_processor.NewFFmpegProcessor()
+	probe := outbound_processor.NewFFprobeProbe()
 	videoRepo := outbound_repository.NewPostgresVideoRepository(dbPool)
 	userRepo := outbound_repository.NewPostgresUserRepository(dbPool)
+	txManager := outbound_repository.NewPostgresTxManager(dbPool)
+	outboxRepo := outbound_repository.NewPostgresOutboxRepository(dbPool)
 	emailer := outbound_email.NewLogEmailAdapter()
 
+	natsURL := getEnv("NATS_URL", "nats://nats1:4222")
+	publisher, err := outbound_messaging.NewNatsEventPublisher(natsURL, getEnv("NATS_EVENTS_STREAM", "VIDEO_EVENTS"), getEnvBool("NATS_PROVISION", false))
+	// Without a broker the outbox is not relayed, so its events wait for a
+	// replica that can publish them instead of being marked sent
+	relayOutbox := err == nil
+	if err != nil {
+		log.Printf("⚠️ Error connecting event publisher to NATS: %v. Progress events will only be logged, lifecycle events stay in the outbox.", err)
+		publisher = outbound_messaging.NewLogEventPublisher()
+	}
+	defer publisher.Close()
+
 	// Initialize Core Service
-	worker := core_services.NewWorkerService(processor, storage, videoRepo, userRepo, emailer)
+	defaults := core_services.DefaultConfig()
+	workerCfg := core_services.Config{
+		WorkerID:          getEnv("WORKER_ID", defaults.WorkerID),
+		JobTimeoutBase:    getEnvDuration("JOB_TIMEOUT_BASE", defaults.JobTimeoutBase),
+		JobTimeoutFactor:  getEnvFloat("JOB_TIMEOUT_FACTOR", defaults.JobTimeoutFactor),
+		JobTimeoutMax:     getEnvDuration("JOB_TIMEOUT_MAX", defaults.JobTimeoutMax),
+		ProgressInterval:  getEnvDuration("PROGRESS_INTERVAL", defaults.ProgressInterval),
+		HeartbeatInterval: getEnvDuration("HEARTBEAT_INTERVAL", defaults.HeartbeatInterval),
+		StaleAfter:        getEnvDuration("STALE_JOB_AFTER", defaults.StaleAfter),
+		MaxAttempts:       getEnvInt("MAX_ATTEMPTS", defaults.MaxAttempts),
+		RetryBaseDelay:    getEnvDuration("RETRY_BASE_DELAY", defaults.RetryBaseDelay),
+		RetryMaxDelay:     getEnvDuration("RETRY_MAX_DELAY", defaults.RetryMaxDelay),
+		StreamFrames:      getEnvBool("STREAM_FRAMES", defaults.StreamFrames),
+		ArchiveFormat:     getEnv("ARCHIVE_FORMAT", defaults.ArchiveFormat),
+		ManifestCSV:       getEnvBool("MANIFEST_CSV", defaults.ManifestCSV),
+	}
+	if domain.ParseArchiveFormat(workerCfg.ArchiveFormat) == "" {
+		log.Fatalf("❌ Unknown ARCHIVE_FORMAT %q, expected one of %s", workerCfg.ArchiveFormat, strings.Join(domain.ArchiveFormats, ", "))
+	}
+	worker := core_services.NewWorkerService(core_services.Dependencies{
+		Processor: processor,
+		Probe:     probe,
+		Storage:   storage,
+		Videos:    videoRepo,
+		Users:     userRepo,
+		Emailer:   emailer,
+		Publisher: publisher,
+		Tx:        txManager,
+		Outbox:    outboxRepo,
+	}, workerCfg)
+	executor := core_services.NewJobExecutor(getEnvInt("WORKER_CONCURRENCY", 1))
 
 	// Initialize Inbound Adapters (NATS and Postgresql Poller)
 
-	// 1. NATS Consumer
-	natsURL := getEnv("NATS_URL", "nats://nats1:4222")
-	consumer, err := inbound_messaging.NewNatsConsumerAdapter(natsURL, worker.ProcessVideoByID)
-	if err != nil {
-		log.Printf("⚠️ Error connecting to NATS: %v. Fallback to polling only.", err)
-	} else {
+	// 1. Event consumer: NATS JetStream, RabbitMQ, Redis Streams, or Postgres LISTEN/NOTIFY for deployments without a broker
+	var consumer ports.EventConsumer
+	switch source := getEnv("EVENT_SOURCE", "nats"); source {
+	case "nats":
+		natsDefaults := inbound_messaging.DefaultNatsConsumerConfig()
+		natsCfg := inbound_messaging.NatsConsumerConfig{
+			Stream:            getEnv("NATS_STREAM", natsDefaults.Stream),
+			StreamSubjects:    getEnvList("NATS_STREAM_SUBJECTS", natsDefaults.StreamSubjects),
+			Subject:           getEnv("NATS_SUBJECT", natsDefaults.Subject),
+			Durable:           getEnv("NATS_DURABLE", natsDefaults.Durable),
+			DeliverPolicy:     getEnv("NATS_DELIVER_POLICY", natsDefaults.DeliverPolicy),
+			AckWait:           getEnvDuration("NATS_ACK_WAIT", natsDefaults.AckWait),
+			MaxAckPending:     getEnvInt("NATS_MAX_ACK_PENDING", natsDefaults.MaxAckPending),
+			MaxDeliver:        getEnvInt("NATS_MAX_DELIVER", natsDefaults.MaxDeliver),
+			DeadLetterSubject: getEnv("NATS_DLQ_SUBJECT", natsDefaults.DeadLetterSubject),
+			Provision:         getEnvBool("NATS_PROVISION", natsDefaults.Provision),
+		}
+		consumer, err = inbound_messaging.NewNatsConsumerAdapter(natsURL, natsCfg, executor, worker.ProcessVideoWithOptions)
+		if errors.Is(err, inbound_messaging.ErrIncompatibleConfig) {
+			// Falling back would hide a misconfiguration that needs fixing on the server
+			log.Fatalf("❌ Refusing to start: %v", err)
+		}
+		if err != nil {
+			log.Printf("⚠️ Error connecting to NATS: %v. Falling back to polling.", err)
+		}
+	case "rabbitmq":
+		rabbitDefaults := inbound_messaging.DefaultRabbitMQConfig()
+		rabbitCfg := inbound_messaging.RabbitMQConfig{
+			URL:             getEnv("RABBITMQ_URL", rabbitDefaults.URL),
+			Queue:           getEnv("RABBITMQ_QUEUE", rabbitDefaults.Queue),
+			Prefetch:        getEnvInt("RABBITMQ_PREFETCH", getEnvInt("WORKER_CONCURRENCY", rabbitDefaults.Prefetch)),
+			MaxDeliver:      getEnvInt("RABBITMQ_MAX_DELIVER", rabbitDefaults.MaxDeliver),
+			DeadLetterQueue: getEnv("RABBITMQ_DLQ", rabbitDefaults.DeadLetterQueue),
+			ConsumerTag:     getEnv("RABBITMQ_CONSUMER_TAG", rabbitDefaults.ConsumerTag),
+		}
+		consumer, err = inbound_messaging.NewRabbitMQConsumerAdapter(rabbitCfg, executor, worker.ProcessVideoWithOptions)
+		if err != nil {
+			log.Printf("⚠️ Error connecting to RabbitMQ: %v. Falling back to polling.", err)
+		}
+	case "redis":
+		redisDefaults := inbound_messaging.DefaultRedisStreamConfig()
+		redisCfg := inbound_messaging.RedisStreamConfig{
+			URL:              getEnv("REDIS_URL", redisDefaults.URL),
+			Stream:           getEnv("REDIS_STREAM", redisDefaults.Stream),
+			Group:            getEnv("REDIS_GROUP", redisDefaults.Group),
+			Consumer:         getEnv("REDIS_CONSUMER", redisDefaults.Consumer),
+			MinIdle:          getEnvDuration("REDIS_MIN_IDLE", redisDefaults.MinIdle),
+			ClaimInterval:    getEnvDuration("REDIS_CLAIM_INTERVAL", redisDefaults.ClaimInterval),
+			MaxDeliver:       getEnvInt("REDIS_MAX_DELIVER", redisDefaults.MaxDeliver),
+			DeadLetterStream: getEnv("REDIS_DLQ_STREAM", redisDefaults.DeadLetterStream),
+		}
+		consumer, err = inbound_messaging.NewRedisStreamConsumerAdapter(redisCfg, executor, worker.ProcessVideoWithOptions)
+		if err != nil {
+			log.Printf("⚠️ Error connecting to Redis: %v. Falling back to polling.", err)
+		}
+	case "postgres":
+		consumer = inbound_pgnotify.NewListenAdapter(dbPool, executor, worker.ProcessVideoWithOptions, worker.ProcessNextPending,
+			getEnvDuration("PG_LISTEN_SCAN_INTERVAL", time.Minute))
+	default:
+		log.Fatalf("❌ Unknown EVENT_SOURCE %q, expected nats, rabbitmq, redis or postgres", source)
+	}
+	if consumer != nil {
 		go func() {
 			if err := consumer.Listen(ctx); err != nil {
-				log.Printf("⚠️ NATS listener stopped: %v", err)
+				log.Printf("⚠️ Event listener stopped: %v", err)
 			}
 		}()
 	}
 
-	// 2. Poller (Fallback Postgresql)
-	//poller := polling.NewPollerAdapter(videoRepo, worker.ProcessVideoByID)
-	//go poller.Start(ctx)
+	// 2. Reaper for videos abandoned in PROCESSING by a crashed worker
+	reaper := inbound_polling.NewReaperAdapter(getEnvDuration("REAPER_INTERVAL", time.Minute), worker.ReapStaleVideos)
+	go reaper.Start(ctx)
+
+	// 3. Outbox relay, ships the lifecycle events committed with the video changes
+	if relayOutbox {
+		relayDefaults := core_services.DefaultOutboxRelayConfig()
+		relay := core_services.NewOutboxRelay(txManager, outboxRepo, publisher, core_services.OutboxRelayConfig{
+			BatchSize:      getEnvInt("OUTBOX_BATCH_SIZE", relayDefaults.BatchSize),
+			MaxAttempts:    getEnvInt("OUTBOX_MAX_ATTEMPTS", relayDefaults.MaxAttempts),
+			RetryBaseDelay: getEnvDuration("OUTBOX_RETRY_BASE_DELAY", relayDefaults.RetryBaseDelay),
+			RetryMaxDelay:  getEnvDuration("OUTBOX_RETRY_MAX_DELAY", relayDefaults.RetryMaxDelay),
+		})
+		relayAdapter := inbound_polling.NewOutboxRelayAdapter(getEnvDuration("OUTBOX_RELAY_INTERVAL", time.Second), relay.Relay)
+		go relayAdapter.Start(ctx)
+	} else {
+		log.Println("⚠️ Outbox relay not started, no event publisher is connected")
+	}
+
+	// 4. Poller (Fallback Postgresql), a single replica polls at a time.
+	// It always runs when no event consumer could be started.
+	pollerEnabled := getEnvBool("POLLER_ENABLED", false)
+	if consumer == nil && !pollerEnabled {
+		log.Println("⚠️ No event consumer running, enabling the poller despite POLLER_ENABLED=false")
+		pollerEnabled = true
+	}
+	if pollerEnabled {
+		pollerLock := outbound_repository.NewPostgresLeaderLock(dbPool, pollerLockKey)
+		pollerDefaults := inbound_polling.DefaultPollerConfig()
+		pollerCfg := inbound_polling.PollerConfig{
+			MinInterval: getEnvDuration("POLL_MIN_INTERVAL", pollerDefaults.MinInterval),
+			MaxInterval: getEnvDuration("POLL_MAX_INTERVAL", pollerDefaults.MaxInterval),
+			PageSize:    getEnvInt("POLL_PAGE_SIZE", pollerDefaults.PageSize),
+		}
+		poller := inbound_polling.NewPollerAdapter(pollerLock, executor, worker.PendingVideos, worker.ProcessVideoWithOptions, pollerCfg)
+		go poller.Start(ctx)
+	}
 
 	log.Println("✅ Worker is up and running. Press Ctrl+C to stop.")
 
@@ -86,9 +257,19 @@ func main() {
 	<-ctx.Done()
 	log.Println("👋 Shutting down worker gracefully...")
 
-	// Give some time for ongoing tasks to finish if needed
-	_, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Inbound adapters stop handing out work on ctx; give in-flight jobs the
+	// grace period, then cancel them so they requeue their videos
+	gracePeriod := getEnvDuration("SHUTDOWN_GRACE_PERIOD", 25*time.Second)
+	log.Printf("⏳ Waiting up to %s for in-flight jobs...", gracePeriod)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
 	defer cancel()
+	if err := executor.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ In-flight jobs were interrupted: %v", err)
+	}
+
+	if consumer != nil {
+		consumer.Close()
+	}
 
 	log.Println("🛑 Worker stopped.")
 }
@@ -128,3 +309,70 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid duration for %s (%q), using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid integer for %s (%q), using %d", key, value, fallback)
+		return fallback
+	}
+	return i
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid boolean for %s (%q), using %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+// getEnvList splits a comma separated value, ignoring empty items
+func getEnvList(key string, fallback []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("⚠️ Invalid number for %s (%q), using %v", key, value, fallback)
+		return fallback
+	}
+	return f
+}
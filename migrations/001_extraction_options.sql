@@ -0,0 +1,3 @@
+-- Per-video frame extraction options (fps, interval, start/end range, max frames).
+-- An empty object means "use the worker defaults".
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS extraction_options JSONB NOT NULL DEFAULT '{}'::jsonb;
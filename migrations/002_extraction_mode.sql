@@ -0,0 +1,2 @@
+-- Extraction mode ("fixed" or "scene") that actually produced the ZIP.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS extraction_mode TEXT;
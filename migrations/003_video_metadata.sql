@@ -0,0 +1,2 @@
+-- Source video metadata read by ffprobe before extraction.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS metadata JSONB;
@@ -0,0 +1,5 @@
+-- Extraction progress reported while a video is PROCESSING.
+ALTER TABLE videos
+    ADD COLUMN IF NOT EXISTS progress_percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS progress_frames INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS progress_eta_seconds DOUBLE PRECISION NOT NULL DEFAULT 0;
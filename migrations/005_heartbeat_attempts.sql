@@ -0,0 +1,8 @@
+-- Liveness of PROCESSING jobs and how many times a video was picked up.
+ALTER TABLE videos
+    ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_videos_processing_liveness
+    ON videos (GREATEST(heartbeat_at, updated_at))
+    WHERE status = 'PROCESSING';
@@ -0,0 +1,6 @@
+-- Which worker claimed a video and when.
+ALTER TABLE videos
+    ADD COLUMN IF NOT EXISTS worker_id TEXT,
+    ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_videos_pending_created_at ON videos (created_at) WHERE status = 'PENDING';
@@ -0,0 +1,4 @@
+-- Retry bookkeeping: last failure and when the next attempt is due.
+ALTER TABLE videos
+    ADD COLUMN IF NOT EXISTS last_error TEXT,
+    ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ;
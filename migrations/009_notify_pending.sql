@@ -0,0 +1,14 @@
+-- Wake up LISTENing workers as soon as a video is uploaded. The payload is the video ID.
+CREATE OR REPLACE FUNCTION notify_video_pending() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('video_pending', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS videos_notify_pending ON videos;
+CREATE TRIGGER videos_notify_pending
+    AFTER INSERT ON videos
+    FOR EACH ROW
+    WHEN (NEW.status = 'PENDING')
+    EXECUTE FUNCTION notify_video_pending();
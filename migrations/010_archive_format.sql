@@ -0,0 +1,4 @@
+-- Archive format ("zip", "tar", "tar.gz" or "tar.zst") and file extension of
+-- the output, so downloads are served with the right content type.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS archive_format TEXT;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS archive_extension TEXT;
@@ -0,0 +1,4 @@
+-- Size and SHA-256 of the output archive, so a download can be verified
+-- against what the worker wrote.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS archive_size BIGINT;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS archive_sha256 TEXT;
@@ -0,0 +1,8 @@
+-- Outbox retries: a failed entry waits until next_attempt_at, and is given
+-- up at abandoned_at once the relay ran out of attempts.
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS abandoned_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS idx_outbox_unsent;
+CREATE INDEX IF NOT EXISTS idx_outbox_unsent ON outbox (id) WHERE sent_at IS NULL AND abandoned_at IS NULL;